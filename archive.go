@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/dedup"
+	"github.com/cyverse-de/dataone-indexer/logger"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+)
+
+// reindexTimeLayouts lists the layouts accepted for the date range of the reindex command.
+var reindexTimeLayouts = []string{"2006-01-02", time.RFC3339}
+
+// archiveMessage adds a decoded message to the raw message archive if the archive is enabled. The message is tagged
+// with the identity of its author so that it can be erased along with the author's events. Failures are logged but
+// don't prevent the message from being processed.
+func (svc *DataoneIndexer) archiveMessage(
+	ctx context.Context, state *indexerState, delivery amqp.Delivery, msg *model.Message, received time.Time,
+) {
+	if svc.archive == nil {
+		return
+	}
+
+	id, err := svc.archive.Add(ctx, &database.ArchivedMessage{
+		ReceivedAt:    received,
+		RoutingKey:    delivery.RoutingKey,
+		ContentType:   delivery.ContentType,
+		SchemaVersion: msg.SchemaVersion,
+		DeliveryTime:  delivery.Timestamp,
+		Body:          delivery.Body,
+		Identity:      state.identities.ArchiveForm(state.subjects.Identity(msg.Author)),
+	})
+	if err != nil {
+		logger.Log.Warnf("unable to archive the message: %s", err)
+		return
+	}
+	msg.ArchiveID = id
+}
+
+// pruneArchive periodically removes messages that are older than the retention period from the raw message archive.
+func (svc *DataoneIndexer) pruneArchive(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-retention)
+		count, err := svc.archive.Prune(context.Background(), cutoff)
+		if err != nil {
+			logger.Log.Errorf("unable to prune the message archive: %s", err)
+		} else if count > 0 {
+			logger.Log.Infof("removed %d archived message(s) received before %s", count, cutoff)
+		}
+		<-ticker.C
+	}
+}
+
+// archivedDelivery reconstructs the AMQP delivery for an archived message.
+func archivedDelivery(m *database.ArchivedMessage) amqp.Delivery {
+	delivery := amqp.Delivery{
+		RoutingKey:  m.RoutingKey,
+		ContentType: m.ContentType,
+		Timestamp:   m.DeliveryTime,
+		Body:        m.Body,
+	}
+	if m.SchemaVersion != "" {
+		delivery.Headers = amqp.Table{model.HeaderSchemaVersion: m.SchemaVersion}
+	}
+	return delivery
+}
+
+// replayRecorder records events for replayed messages, skipping messages that already have recorded events so that
+// replaying the same range more than once doesn't record duplicate events.
+type replayRecorder struct {
+	database.Recorder
+	archive  *database.Archive
+	recorded int
+	skipped  int
+}
+
+// RecordEvent records an event unless events have already been recorded for the archived message.
+func (r *replayRecorder) RecordEvent(ctx context.Context, key string, msg *model.Message) error {
+	if (*r.GetHandlerMap())[key] == nil {
+		return nil
+	}
+	return r.record(ctx, msg, func() error {
+		return r.Recorder.RecordEvent(ctx, key, msg)
+	})
+}
+
+// RecordHandlerEvent records an event unless events have already been recorded for the archived message.
+func (r *replayRecorder) RecordHandlerEvent(ctx context.Context, handler string, msg *model.Message) error {
+	if !database.IsHandlerName(handler) {
+		return nil
+	}
+	return r.record(ctx, msg, func() error {
+		return r.Recorder.RecordHandlerEvent(ctx, handler, msg)
+	})
+}
+
+// record calls a function to record the event for an archived message unless events have already been recorded for
+// the message.
+func (r *replayRecorder) record(ctx context.Context, msg *model.Message, f func() error) error {
+	recorded, err := r.archive.Recorded(ctx, msg.ArchiveID)
+	if err != nil {
+		return err
+	}
+	if recorded {
+		r.skipped++
+		return nil
+	}
+
+	if err := f(); err != nil {
+		return err
+	}
+	r.recorded++
+	return nil
+}
+
+// parseReindexTime parses a date or time given on the command line.
+func parseReindexTime(value string) (time.Time, error) {
+	for _, layout := range reindexTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date or time: %s", value)
+}
+
+// reindex replays the archived messages received within a date range through the current filters and records any
+// events that haven't been recorded yet. Messages are replayed in the order in which they were received, so repeated
+// reads are collapsed in the same way as they would have been if the configuration had been in effect originally.
+func reindex(cfg *viper.Viper, from, to string) {
+	start, err := parseReindexTime(from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid start date: %s\n", err)
+		os.Exit(1)
+	}
+	end := time.Now()
+	if to != "" {
+		if end, err = parseReindexTime(to); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid end date: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Replayed reads are only deduplicated against each other, because the shared claims reflect the live stream.
+	svc := initService(cfg)
+	svc.archive = database.NewArchive(svc.db)
+	svc.dedup = dedup.NewDeduplicator(cfg.GetDuration("dedup.window"), cfg.GetInt("dedup.max-entries"), nil)
+	recorder := &replayRecorder{Recorder: svc.state.recorder, archive: svc.archive}
+	svc.state.recorder = recorder
+
+	// Replay the messages. Messages whose bodies were removed by an erasure or by the identity retention period can't
+	// be replayed, and messages that are rejected by the validator aren't counted as failures.
+	replayed, erased, rejected, failed := 0, 0, 0, 0
+	err = svc.archive.Each(context.Background(), start, end, func(m *database.ArchivedMessage) error {
+		if m.Body == nil {
+			erased++
+			return nil
+		}
+		replayed++
+		err := svc.processDelivery(archivedDelivery(m), m.ReceivedAt, m.ID)
+		if _, ok := err.(model.ValidationErrors); ok {
+			rejected++
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to replay archived message %d: %s\n", m.ID, err)
+			failed++
+		}
+		return nil
+	})
+
+	// Send any spans that are still queued before exiting.
+	shutdownTracing()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read the message archive: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("replayed %d message(s): %d event(s) recorded, %d already recorded, %d erased, %d rejected, %d failed\n",
+		replayed, recorder.recorded, recorder.skipped, erased, rejected, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
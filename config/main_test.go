@@ -0,0 +1,315 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cyverse-de/configurate"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/spf13/viper"
+)
+
+// loadConfig loads a configuration for testing, using the default configuration for unspecified settings.
+func loadConfig(t *testing.T, overrides string) *viper.Viper {
+	cfg, err := configurate.InitDefaultsR(bytes.NewBufferString(overrides), Default)
+	if err != nil {
+		t.Fatalf("unable to load the configuration: %s", err)
+	}
+	return cfg
+}
+
+// findProblem returns true if a list of problems contains a problem for a configuration path.
+func findProblem(problems Problems, path string) bool {
+	for _, p := range problems {
+		if p.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// TestValidConfig verifies that a configuration with a node identifier passes validation.
+func TestValidConfig(t *testing.T) {
+	cfg := loadConfig(t, "dataone:\n  node-id: urn:node:test\n")
+	if problems := Validate(cfg); len(problems) > 0 {
+		t.Errorf("unexpected configuration problems: %s", problems)
+	}
+}
+
+// TestDefaultNodeID verifies that the default configuration is rejected until the node identifier is set.
+func TestDefaultNodeID(t *testing.T) {
+	problems := Validate(loadConfig(t, ""))
+	if !findProblem(problems, "dataone.node-id") {
+		t.Errorf("expected a problem with the node ID but got: %s", problems)
+	}
+}
+
+// TestAllProblemsReported verifies that every problem is reported along with its configuration path.
+func TestAllProblemsReported(t *testing.T) {
+	cfg := loadConfig(t, `
+amqp:
+  uri: "http://rabbit:5672/jobs"
+db:
+  uri: "postgresql://"
+health:
+  max-heartbeat-age: soon
+validation:
+  max-future: -1h
+timestamps:
+  source-timezone: Mars/Olympus_Mons
+robots:
+  ip-ranges:
+    - 192.0.2.0/33
+  action: ignore
+dedup:
+  window: 30s
+  max-entries: 0
+  shared: true
+archive:
+  enabled: true
+  retention: -24h
+pids:
+  table: "pid_mapping; --"
+  cache-ttl: 0s
+tracing:
+  exporter: carrier-pigeon
+dataone:
+  node-id: urn:node:test
+  repository-roots:
+    - ""
+    - relative/path
+    - path: /iplant/home/shared/commons_repo/curated_metadata
+      role: documentation
+  datasets:
+    rules:
+      - root: /iplant/home/shared/commons_repo/curated
+        depth: 0
+  amqp-routing-keys:
+    read: data-object.open
+    update: data-object.mod
+    move: data-object.mod
+    copy: data-object.cp
+irods-audit:
+  routing-key: data-object.mod
+  peps:
+    pep_api_data_obj_write_post: write
+`)
+	problems := Validate(cfg)
+
+	expected := []string{
+		"amqp.uri",
+		"db.uri",
+		"health.max-heartbeat-age",
+		"validation.max-future",
+		"timestamps.source-timezone",
+		"robots.ip-ranges[0]",
+		"robots.action",
+		"dedup.max-entries",
+		"dedup.shared",
+		"archive.retention",
+		"pids.table",
+		"pids.cache-ttl",
+		"tracing.exporter",
+		"dataone.repository-roots[0]",
+		"dataone.repository-roots[1]",
+		"dataone.repository-roots[2].role",
+		"dataone.amqp-routing-keys.read",
+		"dataone.amqp-routing-keys.move",
+		"dataone.amqp-routing-keys.copy",
+		"dataone.datasets.rules[0].depth",
+		"irods-audit.routing-key",
+		"irods-audit.peps.pep_api_data_obj_write_post",
+	}
+	for _, path := range expected {
+		if !findProblem(problems, path) {
+			t.Errorf("expected a problem for %s but got: %s", path, problems)
+		}
+	}
+}
+
+// TestAuditConfig verifies that iRODS audit messages require a persistent identifier table and that handlers can't be
+// enabled for both audit messages and routing keys.
+func TestAuditConfig(t *testing.T) {
+	cfg := loadConfig(t, `
+dataone:
+  node-id: urn:node:test
+irods-audit:
+  routing-key: irods.audit
+`)
+	problems := Validate(cfg)
+	for _, path := range []string{"pids.table", "dataone.amqp-routing-keys.read"} {
+		if !findProblem(problems, path) {
+			t.Errorf("expected a problem for %s but got: %s", path, problems)
+		}
+	}
+
+	// Reads can be recorded from audit messages alone.
+	cfg = loadConfig(t, `
+dataone:
+  node-id: urn:node:test
+  amqp-routing-keys:
+    read: ""
+pids:
+  table: pid_mapping
+irods-audit:
+  routing-key: irods.audit
+`)
+	if problems := Validate(cfg); len(problems) > 0 {
+		t.Errorf("unexpected configuration problems: %s", problems)
+	}
+}
+
+// TestArchiveIdentity verifies that messages can only be archived in identity modes other than raw if the identity
+// retention period is set.
+func TestArchiveIdentity(t *testing.T) {
+	cfg := loadConfig(t, `
+dataone:
+  node-id: urn:node:test
+identity:
+  mode: none
+archive:
+  enabled: true
+`)
+	if problems := Validate(cfg); !findProblem(problems, "archive.enabled") {
+		t.Errorf("expected a problem for archive.enabled but got: %s", problems)
+	}
+
+	cfg.Set("identity.retention", "720h")
+	if problems := Validate(cfg); len(problems) > 0 {
+		t.Errorf("unexpected configuration problems: %s", problems)
+	}
+}
+
+// TestEmptyRoots verifies that an empty list of repository roots is rejected.
+func TestEmptyRoots(t *testing.T) {
+	cfg := loadConfig(t, "dataone:\n  node-id: urn:node:test\n")
+	cfg.Set("dataone.repository-roots", []string{})
+	if !findProblem(Validate(cfg), "dataone.repository-roots") {
+		t.Error("expected a problem for an empty list of repository roots")
+	}
+}
+
+// TestNodes verifies that additional member nodes are decoded and validated.
+func TestNodes(t *testing.T) {
+	cfg := loadConfig(t, `
+dataone:
+  node-id: urn:node:first
+  nodes:
+    - node-id: urn:node:second
+      roots:
+        - path: /iplant/home/shared/second_repo/curated
+          event-types:
+            read: REPLICATE
+    - node-id: urn:node:first
+      roots:
+        - path: relative
+          event-types:
+            read: BOGUS
+            write: READ
+`)
+
+	nodes, err := Nodes(cfg)
+	if err != nil {
+		t.Fatalf("unable to decode the member nodes: %s", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected 3 member nodes but got %d", len(nodes))
+	}
+	if nodes[0].NodeID != "urn:node:first" || len(nodes[0].Roots) != 2 {
+		t.Errorf("unexpected default node: %+v", nodes[0])
+	}
+	if nodes[1].Roots[0].EventTypes["read"] != "REPLICATE" {
+		t.Errorf("unexpected event type overrides: %+v", nodes[1].Roots[0].EventTypes)
+	}
+
+	problems := Validate(cfg)
+	expected := []string{
+		"dataone.nodes[1].node-id",
+		"dataone.nodes[1].roots[0].path",
+		"dataone.nodes[1].roots[0].event-types.read",
+		"dataone.nodes[1].roots[0].event-types.write",
+	}
+	for _, path := range expected {
+		if !findProblem(problems, path) {
+			t.Errorf("expected a problem for %s but got: %s", path, problems)
+		}
+	}
+	if findProblem(problems, "dataone.nodes[0].roots[0].event-types.read") {
+		t.Errorf("unexpected problem with a valid event type override: %s", problems)
+	}
+}
+
+// TestRepositoryRoots verifies that repository roots can be listed as paths or as maps with roles.
+func TestRepositoryRoots(t *testing.T) {
+	cfg := loadConfig(t, `
+dataone:
+  node-id: urn:node:test
+  repository-roots:
+    - /iplant/home/shared/commons_repo/curated
+    - path: /iplant/home/shared/commons_repo/curated_metadata
+      role: metadata
+      event-types:
+        read: REPLICATE
+`)
+
+	roots, err := RepositoryRoots(cfg)
+	if err != nil {
+		t.Fatalf("unable to decode the repository roots: %s", err)
+	}
+	if len(roots) != 2 {
+		t.Fatalf("expected 2 repository roots but got %d", len(roots))
+	}
+	if roots[0].Path != "/iplant/home/shared/commons_repo/curated" || roots[0].RoleOrDefault() != model.RoleData {
+		t.Errorf("unexpected repository root: %+v", roots[0])
+	}
+	if roots[1].RoleOrDefault() != model.RoleMetadata || roots[1].EventTypes["read"] != "REPLICATE" {
+		t.Errorf("unexpected repository root: %+v", roots[1])
+	}
+	if problems := Validate(cfg); len(problems) > 0 {
+		t.Errorf("unexpected configuration problems: %s", problems)
+	}
+}
+
+// TestIdentity verifies that the identity mode and keys are validated.
+func TestIdentity(t *testing.T) {
+	cfg := loadConfig(t, `
+identity:
+  mode: keyed-hash
+  keys:
+    - id: "2026"
+      secret: current
+    - id: "2026"
+      secret: ""
+    - id: "a:b"
+      secret: old
+  retention: -1h
+dataone:
+  node-id: urn:node:test
+`)
+
+	keys, err := IdentityKeys(cfg)
+	if err != nil {
+		t.Fatalf("unable to decode the identity keys: %s", err)
+	}
+	if len(keys) != 3 || keys[0].ID != "2026" || keys[0].Secret != "current" {
+		t.Errorf("unexpected identity keys: %+v", keys)
+	}
+
+	problems := Validate(cfg)
+	expected := []string{
+		"identity.keys[1].id",
+		"identity.keys[1].secret",
+		"identity.keys[2].id",
+		"identity.retention",
+	}
+	for _, path := range expected {
+		if !findProblem(problems, path) {
+			t.Errorf("expected a problem for %s but got: %s", path, problems)
+		}
+	}
+
+	cfg.Set("identity.keys", []interface{}{})
+	if !findProblem(Validate(cfg), "identity.keys") {
+		t.Error("expected a problem for keyed-hash mode without keys")
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/metrics"
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// ArchivedMessage is a decoded message kept in the raw message archive along with the delivery details needed to
+// replay it.
+type ArchivedMessage struct {
+	ID            int64
+	ReceivedAt    time.Time
+	RoutingKey    string
+	ContentType   string
+	SchemaVersion string
+	DeliveryTime  time.Time
+	Body          []byte
+
+	// Identity identifies the user who caused the event so that the message can be erased along with the user's
+	// events. The body is empty if it was removed by an erasure or when the identity retention period expired.
+	Identity model.Identity
+}
+
+// Archive stores raw messages so that they can be replayed when the repository configuration changes. Every valid
+// message is archived, whether or not an event is recorded for it, and events record the ID of the archived message
+// that they were derived from.
+type Archive struct {
+	db *sql.DB
+}
+
+// NewArchive creates a new Archive.
+func NewArchive(db *sql.DB) *Archive {
+	return &Archive{db: db}
+}
+
+// nullTime converts a zero time to nil so that it's stored as NULL.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// Add adds a message to the archive and returns its ID.
+func (a *Archive) Add(ctx context.Context, m *ArchivedMessage) (int64, error) {
+	var id int64
+	err := a.db.QueryRowContext(
+		ctx, addArchivedMessage, m.ReceivedAt, m.RoutingKey, nullString(m.ContentType), nullString(m.SchemaVersion),
+		nullTime(m.DeliveryTime), m.Body, nullString(m.Identity.UserName), nullString(m.Identity.UserZone),
+		nullString(m.Identity.Subject),
+	).Scan(&id)
+	if err != nil {
+		metrics.DatabaseErrors.Inc()
+		return 0, err
+	}
+	metrics.MessagesArchived.Inc()
+	return id, nil
+}
+
+// Each calls a function for every message received within a time range, in the order in which the messages were
+// received. The start of the range is inclusive and the end is exclusive. Iteration stops at the first error.
+func (a *Archive) Each(ctx context.Context, from, to time.Time, f func(*ArchivedMessage) error) error {
+	rows, err := a.db.QueryContext(ctx, listArchivedMessages, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m ArchivedMessage
+		var contentType, schemaVersion sql.NullString
+		var deliveryTime *time.Time
+		err := rows.Scan(&m.ID, &m.ReceivedAt, &m.RoutingKey, &contentType, &schemaVersion, &deliveryTime, &m.Body)
+		if err != nil {
+			return err
+		}
+		m.ContentType = contentType.String
+		m.SchemaVersion = schemaVersion.String
+		if deliveryTime != nil {
+			m.DeliveryTime = *deliveryTime
+		}
+		if err := f(&m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Recorded returns true if any events have been recorded for an archived message.
+func (a *Archive) Recorded(ctx context.Context, id int64) (bool, error) {
+	var recorded bool
+	if err := a.db.QueryRowContext(ctx, archivedMessageRecorded, id).Scan(&recorded); err != nil {
+		metrics.DatabaseErrors.Inc()
+		return false, err
+	}
+	return recorded, nil
+}
+
+// ScrubIdentities removes the bodies and user identifiers from messages received before a cutoff time and returns the
+// number of messages that were changed. The messages can no longer be replayed once their bodies are removed.
+func (a *Archive) ScrubIdentities(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := a.db.ExecContext(ctx, scrubArchivedMessages, cutoff)
+	if err != nil {
+		metrics.DatabaseErrors.Inc()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Prune removes messages received before a cutoff time and returns the number of messages that were removed.
+func (a *Archive) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := a.db.ExecContext(ctx, pruneArchivedMessages, cutoff)
+	if err != nil {
+		metrics.DatabaseErrors.Inc()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
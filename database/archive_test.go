@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// TestArchive verifies that messages can be added to the archive and listed again.
+func TestArchive(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	received := time.Date(2018, time.March, 20, 15, 4, 5, 0, time.UTC)
+	body := []byte(`{"entity":"fakeid","path":"/foo/bar"}`)
+	mock.ExpectQuery("INSERT INTO message_archive").
+		WithArgs(received, "data-object.open", "application/json", nil, nil, body, "key1:0123", "iplant", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(42)))
+	mock.ExpectQuery("SELECT id, received_at").
+		WithArgs(received, received.Add(time.Hour)).
+		WillReturnRows(
+			sqlmock.NewRows(
+				[]string{"id", "received_at", "routing_key", "content_type", "schema_version", "delivery_time", "body"},
+			).
+				AddRow(int64(42), received, "data-object.open", "application/json", nil, nil, body).
+				AddRow(int64(43), received, "data-object.open", "application/json", nil, nil, nil),
+		)
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	// Add the message to the archive.
+	a := NewArchive(db)
+	id, err := a.Add(context.Background(), &ArchivedMessage{
+		ReceivedAt:  received,
+		RoutingKey:  "data-object.open",
+		ContentType: "application/json",
+		Body:        body,
+		Identity:    model.Identity{UserName: "key1:0123", UserZone: "iplant"},
+	})
+	if err != nil {
+		t.Fatalf("error encountered while archiving the message: %s", err)
+	}
+	if id != 42 {
+		t.Errorf("expected archive ID 42 but got %d", id)
+	}
+
+	// List the archived messages.
+	var messages []*ArchivedMessage
+	err = a.Each(context.Background(), received, received.Add(time.Hour), func(m *ArchivedMessage) error {
+		messages = append(messages, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error encountered while listing archived messages: %s", err)
+	}
+	if len(messages) != 2 || messages[0].ID != 42 || string(messages[0].Body) != string(body) {
+		t.Errorf("unexpected archived messages: %+v", messages)
+	}
+	if len(messages) == 2 && messages[1].Body != nil {
+		t.Errorf("expected a removed body to be nil: %+v", messages[1])
+	}
+	if !messages[0].DeliveryTime.IsZero() || messages[0].SchemaVersion != "" {
+		t.Errorf("expected NULL columns to be empty: %+v", messages[0])
+	}
+
+	// Check whether events were recorded for the message.
+	if recorded, err := a.Recorded(context.Background(), 42); err != nil || !recorded {
+		t.Errorf("expected events to be recorded but got %t, %v", recorded, err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestArchiveScrub verifies that identities can be scrubbed from old messages.
+func TestArchiveScrub(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	cutoff := time.Now().Add(-24 * time.Hour)
+	mock.ExpectExec("UPDATE message_archive SET body = NULL").
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	// Scrub the identities from old messages.
+	count, err := NewArchive(db).ScrubIdentities(context.Background(), cutoff)
+	if err != nil {
+		t.Fatalf("error encountered while scrubbing identities: %s", err)
+	}
+	if count != 7 {
+		t.Errorf("expected 7 messages to be scrubbed but got %d", count)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Reasons that a hash chain is broken.
+const (
+	BreakModified  = "modified"
+	BreakMissing   = "missing"
+	BreakTruncated = "truncated"
+)
+
+// ChainedEvent contains the columns of an event that are covered by the hash chain. Each event's hash includes the
+// hash of the previous event recorded for the same node, so editing, deleting or inserting an event breaks the chain.
+// The user identifier columns aren't covered because they're removed when the identity retention period expires and
+// when a user's events are anonymized.
+type ChainedEvent struct {
+	Seq             int64
+	PreviousHash    string
+	PermanentID     string
+	Path            string
+	Event           string
+	DateLogged      time.Time
+	NodeID          string
+	Robot           bool
+	TimestampSource string
+	DatasetID       string
+	Role            string
+}
+
+// Hash computes the hash of an event. Each field is prefixed with its length so that the encoding is unambiguous, and
+// the timestamp is truncated to the precision that PostgreSQL stores.
+func (e *ChainedEvent) Hash() string {
+	fields := []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.PreviousHash,
+		e.PermanentID,
+		e.Path,
+		e.Event,
+		e.DateLogged.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		e.NodeID,
+		strconv.FormatBool(e.Robot),
+		e.TimestampSource,
+		e.DatasetID,
+		e.Role,
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chainHead returns the sequence number and hash of the last event recorded for a node, locking the chain head until
+// the transaction ends so that events for the same node are chained one at a time.
+func chainHead(ctx context.Context, tx *sql.Tx, nodeID string) (int64, string, error) {
+	var seq int64
+	var hash string
+	err := tx.QueryRowContext(ctx, lockChainHead, nodeID).Scan(&seq, &hash)
+	return seq, hash, err
+}
+
+// ChainBreak describes the first place where the hash chain for a node is broken.
+type ChainBreak struct {
+	NodeID string
+	Seq    int64
+	Reason string
+}
+
+// String returns a description of a chain break.
+func (b *ChainBreak) String() string {
+	switch b.Reason {
+	case BreakModified:
+		return fmt.Sprintf("the event with sequence number %d was modified", b.Seq)
+	case BreakMissing:
+		return fmt.Sprintf("events are missing before sequence number %d", b.Seq)
+	default:
+		return fmt.Sprintf("events are missing after sequence number %d", b.Seq)
+	}
+}
+
+// ChainNodes returns the identifiers of the nodes that have hash chains.
+func ChainNodes(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, listChainNodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodeIDs []string
+	for rows.Next() {
+		var nodeID string
+		if err := rows.Scan(&nodeID); err != nil {
+			return nil, err
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	return nodeIDs, rows.Err()
+}
+
+// scanChainedEvent reads a chained event and its stored hash from a result set.
+func scanChainedEvent(rows *sql.Rows) (*ChainedEvent, string, error) {
+	var e ChainedEvent
+	var permanentID, timestampSource, datasetID, role, previousHash sql.NullString
+	var dateLogged *time.Time
+	var hash string
+	err := rows.Scan(
+		&e.Seq, &previousHash, &permanentID, &e.Path, &e.Event, &dateLogged, &e.NodeID, &e.Robot,
+		&timestampSource, &datasetID, &role, &hash,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	e.PreviousHash = previousHash.String
+	e.PermanentID = permanentID.String
+	e.TimestampSource = timestampSource.String
+	e.DatasetID = datasetID.String
+	e.Role = role.String
+	if dateLogged != nil {
+		e.DateLogged = *dateLogged
+	}
+	return &e, hash, nil
+}
+
+// erasedEvent records the hash chain position of an event that was deleted by a user erasure.
+type erasedEvent struct {
+	previousHash string
+	hash         string
+}
+
+// erasedEvents maps sequence numbers to the events that were deleted by user erasures.
+type erasedEvents map[int64]erasedEvent
+
+// listErased returns the events that were deleted from the hash chain for a node by user erasures.
+func listErased(ctx context.Context, db *sql.DB, nodeID string) (erasedEvents, error) {
+	rows, err := db.QueryContext(ctx, listErasedEvents, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	erased := make(erasedEvents)
+	for rows.Next() {
+		var seq int64
+		var previousHash sql.NullString
+		var hash string
+		if err := rows.Scan(&seq, &previousHash, &hash); err != nil {
+			return nil, err
+		}
+		erased[seq] = erasedEvent{previousHash: previousHash.String, hash: hash}
+	}
+	return erased, rows.Err()
+}
+
+// bridge follows the chain from the event at seq across erased events that link to it, stopping before the event at
+// next. It returns the sequence number and hash of the last event reached.
+func (ee erasedEvents) bridge(seq int64, hash string, next int64) (int64, string) {
+	for seq+1 < next {
+		e, ok := ee[seq+1]
+		if !ok || e.previousHash != hash {
+			break
+		}
+		seq, hash = seq+1, e.hash
+	}
+	return seq, hash
+}
+
+// ChainReport describes the result of verifying the hash chain for a node. Verified is the number of events whose
+// hashes were checked before the first break, and Erased is the number of events in the same part of the chain that
+// were deleted by user erasures. Break is nil if the chain is intact.
+type ChainReport struct {
+	Verified int64
+	Erased   int64
+	Break    *ChainBreak
+}
+
+// VerifyChain walks the hash chain for a node and reports the first break. Events deleted by user erasures are
+// skipped using the chain positions recorded when they were deleted, so only gaps that aren't explained by an erasure
+// are reported as missing events.
+func VerifyChain(ctx context.Context, db *sql.DB, nodeID string) (*ChainReport, error) {
+	erased, err := listErased(ctx, db, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, listChainedEvents, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &ChainReport{}
+	var seq, bridged int64
+	var hash string
+	for rows.Next() {
+		e, storedHash, err := scanChainedEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		bridged, hash = erased.bridge(seq, hash, e.Seq)
+		report.Erased += bridged - seq
+		seq = bridged
+		if e.Seq != seq+1 || e.PreviousHash != hash {
+			report.Break = &ChainBreak{NodeID: nodeID, Seq: e.Seq, Reason: BreakMissing}
+			return report, nil
+		}
+		if e.Hash() != storedHash {
+			report.Break = &ChainBreak{NodeID: nodeID, Seq: e.Seq, Reason: BreakModified}
+			return report, nil
+		}
+		seq, hash = e.Seq, storedHash
+		report.Verified++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The last event must match the chain head, or events were removed from the end of the chain.
+	var headSeq int64
+	var headHash string
+	if err := db.QueryRowContext(ctx, getChainHead, nodeID).Scan(&headSeq, &headHash); err != nil {
+		return nil, err
+	}
+	bridged, hash = erased.bridge(seq, hash, headSeq+1)
+	report.Erased += bridged - seq
+	seq = bridged
+	if headSeq != seq || headHash != hash {
+		report.Break = &ChainBreak{NodeID: nodeID, Seq: seq, Reason: BreakTruncated}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// The columns returned by the query used to list chained events.
+var chainColumns = []string{
+	"chain_seq", "previous_hash", "permanent_id", "irods_path", "event", "date_logged", "node_identifier", "robot",
+	"timestamp_source", "dataset_id", "root_role", "row_hash",
+}
+
+// buildChain returns a hash chain of events for testing.
+func buildChain(n int) []*ChainedEvent {
+	var events []*ChainedEvent
+	previousHash := ""
+	logged := time.Date(2018, time.March, 20, 15, 4, 5, 123456000, time.UTC)
+	for i := 1; i <= n; i++ {
+		e := &ChainedEvent{
+			Seq:             int64(i),
+			PreviousHash:    previousHash,
+			PermanentID:     "doi:10.5072/FK2",
+			Path:            "/iplant/home/shared/commons_repo/curated/foo.txt",
+			Event:           ETRead,
+			DateLogged:      logged.Add(time.Duration(i) * time.Minute),
+			NodeID:          "fakenode",
+			TimestampSource: model.TimestampSourceMessage,
+			Role:            model.RoleData,
+		}
+		previousHash = e.Hash()
+		events = append(events, e)
+	}
+	return events
+}
+
+// chainRows converts chained events to the rows returned by the query used to list them.
+func chainRows(events []*ChainedEvent) *sqlmock.Rows {
+	rows := sqlmock.NewRows(chainColumns)
+	for _, e := range events {
+		var previousHash interface{}
+		if e.PreviousHash != "" {
+			previousHash = e.PreviousHash
+		}
+		rows.AddRow(
+			e.Seq, previousHash, e.PermanentID, e.Path, e.Event, e.DateLogged, e.NodeID, e.Robot,
+			e.TimestampSource, nil, e.Role, e.Hash(),
+		)
+	}
+	return rows
+}
+
+// erasedRows converts chained events to the rows returned by the query used to list erased events.
+func erasedRows(events []*ChainedEvent) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"chain_seq", "previous_hash", "row_hash"})
+	for _, e := range events {
+		rows.AddRow(e.Seq, e.PreviousHash, e.Hash())
+	}
+	return rows
+}
+
+// TestHash verifies that the hash of an event depends on its contents.
+func TestHash(t *testing.T) {
+	e := buildChain(1)[0]
+	hash := e.Hash()
+	if len(hash) != 64 {
+		t.Errorf("unexpected hash: %s", hash)
+	}
+
+	// The hash doesn't depend on precision that PostgreSQL doesn't store or on the time zone.
+	same := *e
+	same.DateLogged = e.DateLogged.Add(999 * time.Nanosecond).In(time.FixedZone("MST", -7*60*60))
+	if same.Hash() != hash {
+		t.Error("expected the hash to be unaffected by sub-microsecond precision and the time zone")
+	}
+
+	changed := *e
+	changed.Path = "/iplant/home/shared/commons_repo/curated/bar.txt"
+	if changed.Hash() == hash {
+		t.Error("expected the hash to change when the path changes")
+	}
+}
+
+// TestVerifyChain verifies that intact chains are accepted, that gaps left by erasures are skipped and that each kind
+// of break is detected.
+func TestVerifyChain(t *testing.T) {
+	intact := buildChain(3)
+	modified := buildChain(3)
+	modifiedRows := chainRows(modified[:1])
+	modifiedRows.AddRow(
+		int64(2), modified[0].Hash(), "doi:10.5072/FK3", modified[1].Path, ETRead, modified[1].DateLogged, "fakenode",
+		false, model.TimestampSourceMessage, nil, model.RoleData, modified[1].Hash(),
+	)
+
+	tests := []struct {
+		name     string
+		rows     *sqlmock.Rows
+		erased   []*ChainedEvent
+		head     *ChainedEvent
+		seq      int64
+		reason   string
+		verified int64
+	}{
+		{"intact", chainRows(intact), nil, intact[2], 0, "", 3},
+		{"modified", modifiedRows, nil, nil, 2, BreakModified, 1},
+		{"missing", chainRows([]*ChainedEvent{intact[0], intact[2]}), nil, nil, 3, BreakMissing, 1},
+		{"truncated", chainRows(intact[:2]), nil, intact[2], 2, BreakTruncated, 2},
+		{"erased", chainRows([]*ChainedEvent{intact[0], intact[2]}), intact[1:2], intact[2], 0, "", 2},
+		{"erased last", chainRows(intact[:1]), intact[1:], intact[2], 0, "", 1},
+	}
+	for _, tc := range tests {
+
+		// Create the stub database connection.
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening stub database connection: %s", err)
+		}
+
+		// Describe the expected database actions.
+		mock.ExpectQuery("SELECT chain_seq, previous_hash, row_hash FROM erased_events").
+			WithArgs("fakenode").
+			WillReturnRows(erasedRows(tc.erased))
+		mock.ExpectQuery("SELECT chain_seq, previous_hash, permanent_id").WithArgs("fakenode").WillReturnRows(tc.rows)
+		if tc.head != nil {
+			mock.ExpectQuery("SELECT last_seq, last_hash FROM event_chain_heads").
+				WithArgs("fakenode").
+				WillReturnRows(sqlmock.NewRows([]string{"last_seq", "last_hash"}).AddRow(tc.head.Seq, tc.head.Hash()))
+		}
+
+		// Verify the chain.
+		report, err := VerifyChain(context.Background(), db, "fakenode")
+		if err != nil {
+			t.Fatalf("%s: error encountered while verifying the chain: %s", tc.name, err)
+		}
+		if report.Verified != tc.verified || (tc.reason == "" && report.Erased != int64(len(tc.erased))) {
+			t.Errorf("%s: unexpected counts: %d verified, %d erased", tc.name, report.Verified, report.Erased)
+		}
+		chainBreak := report.Break
+		switch {
+		case tc.reason == "" && chainBreak != nil:
+			t.Errorf("%s: unexpected chain break: %s", tc.name, chainBreak)
+		case tc.reason != "" && chainBreak == nil:
+			t.Errorf("%s: expected a chain break but none was found", tc.name)
+		case tc.reason != "" && (chainBreak.Reason != tc.reason || chainBreak.Seq != tc.seq):
+			t.Errorf("%s: expected %s at %d but got %s at %d", tc.name, tc.reason, tc.seq, chainBreak.Reason,
+				chainBreak.Seq)
+		}
+
+		// Verify that the expectations were met.
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", tc.name, err)
+		}
+	}
+}
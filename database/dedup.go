@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/identity"
+	"github.com/cyverse-de/dataone-indexer/metrics"
+)
+
+// DedupStore records read deduplication claims in the database so that they're shared by every replica. Keys are
+// stored as keyed hashes so that user names can't be recovered from the claims table, even by hashing a list of user
+// names. Every replica must use the same key.
+type DedupStore struct {
+	db  *sql.DB
+	key identity.Key
+}
+
+// NewDedupStore creates a new DedupStore that hashes keys with an identity key.
+func NewDedupStore(db *sql.DB, key identity.Key) *DedupStore {
+	return &DedupStore{db: db, key: key}
+}
+
+// Claim attempts to claim the deduplication window for a key at a time. It returns true if the key hasn't been
+// claimed within the window of that time.
+func (s *DedupStore) Claim(ctx context.Context, key string, t time.Time, window time.Duration) (bool, error) {
+	var claimedKey string
+	err := s.db.QueryRowContext(ctx, claimDedupKey, s.key.Hash(key), t, window.Seconds()).Scan(&claimedKey)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		metrics.DatabaseErrors.Inc()
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// Prune removes claims that are more than a window older than the most recent claim and returns the number of claims
+// that were removed.
+func (s *DedupStore) Prune(ctx context.Context, window time.Duration) (int64, error) {
+	result, err := s.db.ExecContext(ctx, pruneDedupKeys, window.Seconds())
+	if err != nil {
+		metrics.DatabaseErrors.Inc()
+		return 0, err
+	}
+	return result.RowsAffected()
+}
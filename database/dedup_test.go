@@ -0,0 +1,77 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/identity"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// TestDedupClaim verifies that deduplication claims are recorded in the database.
+func TestDedupClaim(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	now := time.Now()
+	key := identity.Key{ID: "key1", Secret: "secret"}
+	digest := key.Hash("entity|alice#iplant")
+	mock.ExpectQuery("INSERT INTO read_dedup").
+		WithArgs(digest, now, 30.0).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_key"}).AddRow(digest))
+	mock.ExpectQuery("INSERT INTO read_dedup").
+		WithArgs(digest, now, 30.0).
+		WillReturnRows(sqlmock.NewRows([]string{"dedup_key"}))
+
+	// The first claim should succeed and the second should fail.
+	s := NewDedupStore(db, key)
+	if claimed, err := s.Claim(context.Background(), "entity|alice#iplant", now, 30*time.Second); err != nil {
+		t.Fatalf("error encountered while claiming the key: %s", err)
+	} else if !claimed {
+		t.Error("the first claim failed")
+	}
+	if claimed, err := s.Claim(context.Background(), "entity|alice#iplant", now, 30*time.Second); err != nil {
+		t.Fatalf("error encountered while claiming the key: %s", err)
+	} else if claimed {
+		t.Error("the second claim succeeded")
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestDedupPrune verifies that claims are pruned relative to the most recent claim rather than the current time.
+func TestDedupPrune(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	mock.ExpectExec(`DELETE FROM read_dedup\s+WHERE last_claimed < \(SELECT max\(last_claimed\) FROM read_dedup\)`).
+		WithArgs(30.0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// Prune the claims.
+	s := NewDedupStore(db, identity.Key{ID: "key1", Secret: "secret"})
+	if count, err := s.Prune(context.Background(), 30*time.Second); err != nil {
+		t.Fatalf("error encountered while pruning claims: %s", err)
+	} else if count != 2 {
+		t.Errorf("unexpected number of claims pruned: %d", count)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -48,8 +49,13 @@ func (r MockRecorder) GetHandlerMap() *HandlerMap {
 }
 
 // RecordEvent records an event in the database if there is a handler for the given routing key.
-func (r MockRecorder) RecordEvent(key string, msg *model.Message) error {
-	return dispatchMessage(r, key, msg)
+func (r MockRecorder) RecordEvent(ctx context.Context, key string, msg *model.Message) error {
+	return dispatchMessage(ctx, r, key, msg)
+}
+
+// RecordHandlerEvent records an event in the database using the handler with the given name.
+func (r MockRecorder) RecordHandlerEvent(ctx context.Context, handler string, msg *model.Message) error {
+	return dispatchHandlerMessage(ctx, r, handler, msg)
 }
 
 // newMockRecorder returns a mock event recorder with default settings.
@@ -57,7 +63,7 @@ func newMockRecorder() *MockRecorder {
 	var r MockRecorder
 	r = MockRecorder{
 		handlers: &HandlerMap{
-			RKRead: func(recorder Recorder, key string, msg *model.Message) error {
+			RKRead: func(ctx context.Context, recorder Recorder, key string, msg *model.Message) error {
 				r.callMap.Read++
 				return nil
 			},
@@ -71,7 +77,7 @@ func newMockRecorder() *MockRecorder {
 // TestDispatch verifies that messages are dispatched as expected.
 func TestDispatch(t *testing.T) {
 	r := newMockRecorder()
-	r.RecordEvent(RKRead, nil)
+	r.RecordEvent(context.Background(), RKRead, nil)
 
 	// The method to record read events should have been called.
 	if r.callMap.Read != 1 {
@@ -82,7 +88,7 @@ func TestDispatch(t *testing.T) {
 // TestNonDispatch verifies that messages that should not be dispatched are not.
 func TestNonDispatch(t *testing.T) {
 	r := newMockRecorder()
-	r.RecordEvent(RKFake, nil)
+	r.RecordEvent(context.Background(), RKFake, nil)
 
 	// The method to record read events should not have been called.
 	if r.callMap.Read != 0 {
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Erasure actions.
+const (
+	EraseDelete    = "delete"
+	EraseAnonymize = "anonymize"
+)
+
+// ErasureRequest describes the events to erase and how to erase them. Because user identifiers may be stored either
+// as raw values or as keyed hashes, each identifier is matched against a list of candidate stored values. Events are
+// erased if they match either the user or the subject.
+type ErasureRequest struct {
+	Action string
+
+	// UserNames lists the stored forms of the user name. UserZone restricts the match to a single zone if it's not
+	// empty.
+	UserNames []string
+	UserZone  string
+
+	// Subjects lists the stored forms of the DataONE subject.
+	Subjects []string
+
+	// RequestID is an opaque identifier that links the audit records for a single request. Criteria describes the
+	// erased user in the audit record. It shouldn't contain the user identifiers themselves or anything that can be
+	// derived from them without a secret key.
+	RequestID   string
+	Criteria    string
+	RequestedBy string
+	Reference   string
+}
+
+// ErasureResult describes the changes made to a single table.
+type ErasureResult struct {
+	Table        string
+	RowsAffected int64
+}
+
+// erasableTable describes a table containing user identifiers and how to erase them.
+type erasableTable struct {
+	name            string
+	userNameColumn  string
+	userZoneColumn  string
+	subjectColumn   string
+	anonymizeClause string
+
+	// chained indicates that the rows are covered by the event log hash chain. The chain positions of deleted rows
+	// are recorded so that the gaps they leave can be told apart from tampering.
+	chained bool
+}
+
+// erasableTables lists every table maintained by the indexer that contains user identifiers.
+var erasableTables = []erasableTable{
+	{
+		name:            "event_log",
+		userNameColumn:  "user_name",
+		userZoneColumn:  "user_zone",
+		subjectColumn:   "subject",
+		anonymizeClause: "user_name = NULL, user_zone = NULL, subject = NULL",
+		chained:         true,
+	},
+	{
+		name:            "message_archive",
+		userNameColumn:  "user_name",
+		userZoneColumn:  "user_zone",
+		subjectColumn:   "subject",
+		anonymizeClause: "user_name = NULL, user_zone = NULL, subject = NULL, body = NULL",
+	},
+}
+
+// whereClause builds the condition used to select the rows to erase from a table along with its arguments.
+func (t erasableTable) whereClause(req *ErasureRequest) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if len(req.UserNames) > 0 {
+		args = append(args, pq.Array(req.UserNames))
+		condition := fmt.Sprintf("%s = ANY($%d)", t.userNameColumn, len(args))
+		if req.UserZone != "" {
+			args = append(args, req.UserZone)
+			condition = fmt.Sprintf("(%s AND %s = $%d)", condition, t.userZoneColumn, len(args))
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(req.Subjects) > 0 {
+		args = append(args, pq.Array(req.Subjects))
+		conditions = append(conditions, fmt.Sprintf("%s = ANY($%d)", t.subjectColumn, len(args)))
+	}
+
+	return strings.Join(conditions, " OR "), args
+}
+
+// statement builds the statement used to erase the matching rows from a table along with its arguments.
+func (t erasableTable) statement(req *ErasureRequest) (string, []interface{}) {
+	where, args := t.whereClause(req)
+	if req.Action == EraseDelete {
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", t.name, where), args
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s", t.name, t.anonymizeClause, where), args
+}
+
+// erasedEventsStatement builds the statement used to record the chain positions of the rows that are about to be
+// deleted from a table along with its arguments.
+func (t erasableTable) erasedEventsStatement(req *ErasureRequest) (string, []interface{}) {
+	where, args := t.whereClause(req)
+	args = append(args, req.RequestID)
+	return fmt.Sprintf(addErasedEvents, len(args), t.name, where), args
+}
+
+// EraseUser deletes or anonymizes every row tied to a user in every table that the indexer maintains. All of the
+// changes, along with an audit record for each table and the chain positions of deleted events, are made in a single
+// transaction.
+func EraseUser(ctx context.Context, db *sql.DB, req *ErasureRequest) (results []ErasureResult, err error) {
+	if req.Action != EraseDelete && req.Action != EraseAnonymize {
+		return nil, fmt.Errorf("unsupported erasure action: %s", req.Action)
+	}
+	if len(req.UserNames) == 0 && len(req.Subjects) == 0 {
+		return nil, fmt.Errorf("a user name or subject is required")
+	}
+
+	// Begin a transaction.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Erase the rows from each table and record what was changed.
+	for _, table := range erasableTables {
+		if table.chained && req.Action == EraseDelete {
+			query, args := table.erasedEventsStatement(req)
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return nil, fmt.Errorf("unable to record the events erased from %s: %s", table.name, err)
+			}
+		}
+
+		query, args := table.statement(req)
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to erase rows from %s: %s", table.name, err)
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("unable to count the rows erased from %s: %s", table.name, err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx, addErasureAudit,
+			req.Action, req.Criteria, table.name, count, req.RequestedBy, req.Reference, req.RequestID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to record the erasure audit record: %s", err)
+		}
+
+		results = append(results, ErasureResult{Table: table.name, RowsAffected: count})
+	}
+
+	// Commit the transaction.
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
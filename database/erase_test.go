@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// getErasureRequest returns an erasure request that can be used for testing.
+func getErasureRequest(action string) *ErasureRequest {
+	return &ErasureRequest{
+		Action:      action,
+		UserNames:   []string{"ipcdev", "key1:0123"},
+		UserZone:    "iplant",
+		Subjects:    []string{"http://orcid.org/0000-0002-1825-0097"},
+		RequestID:   "3f2a9c0d1e4b5a6978c8d7e6f5a4b3c2",
+		Criteria:    "user key1:4567",
+		RequestedBy: "admin",
+		Reference:   "TICKET-1",
+	}
+}
+
+// TestAnonymizeUser verifies that a user's events and archived messages can be anonymized and that the changes are
+// audited.
+func TestAnonymizeUser(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	req := getErasureRequest(EraseAnonymize)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE event_log SET .* \(user_name = ANY\(\$1\) AND user_zone = \$2\) OR subject = ANY\(\$3\)`).
+		WithArgs(sqlmock.AnyArg(), "iplant", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("INSERT INTO erasure_audit").
+		WithArgs(EraseAnonymize, req.Criteria, "event_log", int64(4), "admin", "TICKET-1", req.RequestID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE message_archive SET .*body = NULL WHERE \(user_name = ANY\(\$1\) AND user_zone = \$2\)`).
+		WithArgs(sqlmock.AnyArg(), "iplant", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec("INSERT INTO erasure_audit").
+		WithArgs(EraseAnonymize, req.Criteria, "message_archive", int64(5), "admin", "TICKET-1", req.RequestID).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	// Erase the user.
+	results, err := EraseUser(context.Background(), db, req)
+	if err != nil {
+		t.Fatalf("error encountered while erasing the user: %s", err)
+	}
+	if len(results) != 2 || results[0].Table != "event_log" || results[0].RowsAffected != 4 ||
+		results[1].Table != "message_archive" || results[1].RowsAffected != 5 {
+		t.Errorf("unexpected erasure results: %+v", results)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestDeleteSubject verifies that a subject's events and archived messages can be deleted and that the chain positions
+// of the events are recorded.
+func TestDeleteSubject(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	req := getErasureRequest(EraseDelete)
+	req.UserNames = nil
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO erased_events .* FROM event_log\s+WHERE .* \(subject = ANY\(\$1\)\)`).
+		WithArgs(sqlmock.AnyArg(), req.RequestID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM event_log WHERE subject = ANY\(\$1\)`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO erasure_audit").
+		WithArgs(EraseDelete, req.Criteria, "event_log", int64(2), "admin", "TICKET-1", req.RequestID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM message_archive WHERE subject = ANY\(\$1\)`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO erasure_audit").
+		WithArgs(EraseDelete, req.Criteria, "message_archive", int64(3), "admin", "TICKET-1", req.RequestID).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	// Erase the subject.
+	if _, err := EraseUser(context.Background(), db, req); err != nil {
+		t.Fatalf("error encountered while erasing the subject: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestErasureRollback verifies that no changes are committed if the audit record can't be written.
+func TestErasureRollback(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	req := getErasureRequest(EraseDelete)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO erased_events").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM event_log").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO erasure_audit").WillReturnError(fmt.Errorf("permission denied"))
+	mock.ExpectRollback()
+
+	// Attempt to erase the user.
+	if _, err := EraseUser(context.Background(), db, req); err == nil {
+		t.Error("an error was expected but none was returned")
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
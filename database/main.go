@@ -1,20 +1,28 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"time"
 
+	"github.com/cyverse-de/dataone-indexer/metrics"
 	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/cyverse-de/dataone-indexer/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // HandlerFunction represents a function used to handle an incoming message.
-type HandlerFunction func(Recorder, string, *model.Message) error
+type HandlerFunction func(context.Context, Recorder, string, *model.Message) error
 
 // HandlerMap represents a map from AMQP routing key to message handler function.
 type HandlerMap map[string]HandlerFunction
 
-// Recorder is an interface for recording DataONE events.
+// Recorder is an interface for recording DataONE events. Events are normally dispatched by AMQP routing key, but
+// messages that select their own handlers, such as messages from the iRODS audit plugin, are dispatched by handler
+// name.
 type Recorder interface {
-	RecordEvent(key string, msg *model.Message) error
+	RecordEvent(ctx context.Context, key string, msg *model.Message) error
+	RecordHandlerEvent(ctx context.Context, handler string, msg *model.Message) error
 	GetHandlerMap() *HandlerMap
 	GetNodeID() string
 	GetDb() *sql.DB
@@ -22,9 +30,18 @@ type Recorder interface {
 
 // Dispatches a message for an arbitrary recorder. The primary reason this task is split into a separate function
 // is to test the dipatch mechanism independently.
-func dispatchMessage(r Recorder, key string, msg *model.Message) error {
+func dispatchMessage(ctx context.Context, r Recorder, key string, msg *model.Message) error {
 	if f := (*r.GetHandlerMap())[key]; f != nil {
-		return f(r, key, msg)
+		return f(ctx, r, key, msg)
+	}
+	return nil
+}
+
+// dispatchHandlerMessage dispatches a message to the function for a handler name. Handlers selected by name don't
+// depend on the routing key settings.
+func dispatchHandlerMessage(ctx context.Context, r Recorder, handler string, msg *model.Message) error {
+	if f := handlerFunctions[handler]; f != nil {
+		return f(ctx, r, "", msg)
 	}
 	return nil
 }
@@ -36,36 +53,340 @@ type DefaultRecorder struct {
 	nodeID   string
 }
 
-// KeyNames represents a mapping from DataONE event type to AMQP routing keys.
+// Handler names. These are used as keys in the routing key and event type override settings.
+const (
+	HandlerRead   = "read"
+	HandlerCreate = "create"
+	HandlerUpdate = "update"
+	HandlerDelete = "delete"
+	HandlerMove   = "move"
+)
+
+// HandlerNames lists every handler name.
+var HandlerNames = []string{HandlerRead, HandlerCreate, HandlerUpdate, HandlerDelete, HandlerMove}
+
+// IsHandlerName returns true if a string is a known handler name.
+func IsHandlerName(name string) bool {
+	for _, n := range HandlerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeIDFor returns the identifier of the member node that an event should be recorded for. The node that owns the
+// path in the message takes precedence over the recorder's default node.
+func nodeIDFor(r Recorder, msg *model.Message) string {
+	if msg.NodeID != "" {
+		return msg.NodeID
+	}
+	return r.GetNodeID()
+}
+
+// eventTypeFor returns the event type that a handler should record for a message, taking any overrides associated
+// with the repository root into account.
+func eventTypeFor(msg *model.Message, handler, defaultEventType string) string {
+	if eventType := msg.EventTypes[handler]; eventType != "" {
+		return eventType
+	}
+	return defaultEventType
+}
+
+// nullString converts an empty string to nil so that it's stored as NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt64 converts zero to nil so that it's stored as NULL.
+func nullInt64(n int64) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// nullSize converts a missing object size to nil so that it's stored as NULL.
+func nullSize(size *int64) interface{} {
+	if size == nil {
+		return nil
+	}
+	return *size
+}
+
+// permanentID returns the identifier that an event is recorded under. The persistent identifier is preferred, and the
+// entity ID is used if the persistent identifier couldn't be resolved.
+func permanentID(msg *model.Message) interface{} {
+	if msg.PID != "" {
+		return msg.PID
+	}
+	return nullString(msg.Entity)
+}
+
+// identityColumns returns the values of the columns that identify the user who caused an event.
+func identityColumns(msg *model.Message) (userName, userZone, subject interface{}) {
+	id := msg.Identity
+	return nullString(id.UserName), nullString(id.UserZone), nullString(id.Subject)
+}
+
+// ScrubIdentities removes the user identifiers from events logged before a cutoff time and returns the number of
+// events that were changed. The events themselves are retained so that aggregate counts are unaffected.
+func ScrubIdentities(ctx context.Context, db *sql.DB, cutoff time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, scrubIdentities, cutoff)
+	if err != nil {
+		metrics.DatabaseErrors.Inc()
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	metrics.IdentitiesScrubbed.Add(float64(count))
+	return count, nil
+}
+
+// inventoryStatements maps handler names to the statements used to update the inventory of repository objects.
+// Handlers that don't change the inventory are omitted.
+var inventoryStatements = map[string]string{
+	HandlerCreate: createInventoryItem,
+	HandlerUpdate: updateInventoryItem,
+	HandlerMove:   updateInventoryItem,
+	HandlerDelete: deleteInventoryItem,
+}
+
+// KeyNames represents a mapping from DataONE event type to AMQP routing keys. Events are only recorded for handlers
+// with non-empty routing keys.
 type KeyNames struct {
-	Read string
+	Read   string
+	Create string
+	Update string
+	Delete string
+	Move   string
 }
 
 // recordReadEvent is the function that DefaultRecorder uses to record file accesses.
-func recordReadEvent(r Recorder, key string, msg *model.Message) error {
+func recordReadEvent(ctx context.Context, r Recorder, key string, msg *model.Message) error {
+	return recordEvent(ctx, r, msg, HandlerRead, ETRead)
+}
+
+// recordCreateEvent is the function that DefaultRecorder uses to record file uploads.
+func recordCreateEvent(ctx context.Context, r Recorder, key string, msg *model.Message) error {
+	return recordEvent(ctx, r, msg, HandlerCreate, ETCreate)
+}
+
+// recordUpdateEvent is the function that DefaultRecorder uses to record file modifications.
+func recordUpdateEvent(ctx context.Context, r Recorder, key string, msg *model.Message) error {
+	return recordEvent(ctx, r, msg, HandlerUpdate, ETUpdate)
+}
+
+// recordDeleteEvent is the function that DefaultRecorder uses to record file deletions.
+func recordDeleteEvent(ctx context.Context, r Recorder, key string, msg *model.Message) error {
+	return recordEvent(ctx, r, msg, HandlerDelete, ETDelete)
+}
+
+// recordMoveEvent is the function that DefaultRecorder uses to record files that are moved or renamed. DataONE has no
+// move event type, so moves are recorded as updates at the destination path.
+func recordMoveEvent(ctx context.Context, r Recorder, key string, msg *model.Message) error {
+	return recordEvent(ctx, r, msg, HandlerMove, ETUpdate)
+}
+
+// handlerFunctions maps each handler name to the function that records its events.
+var handlerFunctions = map[string]HandlerFunction{
+	HandlerRead:   recordReadEvent,
+	HandlerCreate: recordCreateEvent,
+	HandlerUpdate: recordUpdateEvent,
+	HandlerDelete: recordDeleteEvent,
+	HandlerMove:   recordMoveEvent,
+}
+
+// recordEvent inserts a single event into the database and updates the inventory in the same transaction.
+func recordEvent(ctx context.Context, r Recorder, msg *model.Message, handler, defaultEventType string) (err error) {
+	eventType := eventTypeFor(msg, handler, defaultEventType)
+	nodeID := nodeIDFor(r, msg)
+
+	ctx, span := tracing.Start(ctx, "insert event")
+	span.SetAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("dataone.event_type", eventType),
+		attribute.String("dataone.node_id", nodeID),
+	)
+	defer func() {
+		tracing.SetError(span, err)
+		span.End()
+	}()
 
 	// Begin a transaction.
-	tx, err := r.GetDb().Begin()
+	tx, err := r.GetDb().BeginTx(ctx, nil)
 	if err != nil {
+		metrics.DatabaseErrors.Inc()
 		return err
 	}
 
 	// Insert the row into the database.
-	_, err = tx.Exec(addEvent, msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID())
-	if err != nil {
+	if err = insertEvent(ctx, tx, msg, eventType, nodeID); err != nil {
+		metrics.DatabaseErrors.Inc()
 		tx.Rollback()
 		return err
 	}
 
+	// Record a package-level event for the dataset containing the object.
+	packageEvent := handler == HandlerRead && msg.PackageEvent && msg.DatasetID != ""
+	if packageEvent {
+		if err = insertEvent(ctx, tx, packageMessage(msg), eventType, nodeID); err != nil {
+			metrics.DatabaseErrors.Inc()
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Update the inventory.
+	if inventoryStatements[handler] != "" {
+		if err = updateInventory(ctx, tx, msg, handler, nodeID); err != nil {
+			metrics.DatabaseErrors.Inc()
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// Commit the transaction.
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		metrics.DatabaseErrors.Inc()
+		return err
+	}
+
+	recordEventMetrics(eventType, handler, msg)
+	if packageEvent {
+		metrics.PackageEventsRecorded.Inc()
+	}
+	return nil
+}
+
+// inventoryUUID returns the UUID that identifies the object in a message in the inventory. Messages without entity
+// IDs refer to the live object at the path in the inventory, or at the source path for moves. Nil is returned if the
+// object can't be found.
+func inventoryUUID(ctx context.Context, tx *sql.Tx, msg *model.Message) (interface{}, error) {
+	if msg.Entity != "" {
+		return msg.Entity, nil
+	}
+
+	path := msg.Path
+	if msg.SourcePath != "" {
+		path = msg.SourcePath
+	}
+
+	var uuid string
+	err := tx.QueryRowContext(ctx, findInventoryUUID, path).Scan(&uuid)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	default:
+		return uuid, nil
+	}
+}
+
+// updateInventory updates the inventory entry for the object in a message. The inventory is keyed by UUID, and the
+// persistent identifier is recorded alongside it when it's known. The inventory isn't updated if the UUID of the
+// object can't be found.
+func updateInventory(ctx context.Context, tx *sql.Tx, msg *model.Message, handler, nodeID string) error {
+	uuid, err := inventoryUUID(ctx, tx, msg)
+	if err != nil || uuid == nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx, inventoryStatements[handler], uuid, nullString(msg.PID), msg.Path, nodeID, msg.Timestamp.ToTime(),
+		nullSize(msg.Size), nullString(msg.Checksum),
+	)
+	return err
+}
+
+// packageMessage returns a copy of a message that describes the dataset containing the object rather than the object
+// itself. It's used to record package-level events, which refer to the dataset's resource map.
+func packageMessage(msg *model.Message) *model.Message {
+	pkg := *msg
+	pkg.PID = msg.DatasetID
+	pkg.Path = msg.DatasetPath
+	pkg.Role = model.RoleResourceMap
+	return &pkg
+}
+
+// insertEvent inserts a single row into the event log and appends it to the hash chain for the node.
+func insertEvent(ctx context.Context, tx *sql.Tx, msg *model.Message, eventType, nodeID string) error {
+	seq, previousHash, err := chainHead(ctx, tx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	// Compute the hash of the event.
+	e := &ChainedEvent{
+		Seq:             seq + 1,
+		PreviousHash:    previousHash,
+		Path:            msg.Path,
+		Event:           eventType,
+		NodeID:          nodeID,
+		Robot:           msg.Robot,
+		TimestampSource: msg.TimestampSource,
+		DatasetID:       msg.DatasetID,
+		Role:            msg.Role,
+	}
+	if id, ok := permanentID(msg).(string); ok {
+		e.PermanentID = id
+	}
+	if msg.Timestamp != nil {
+		e.DateLogged = *msg.Timestamp.ToTime()
+	}
+	hash := e.Hash()
+
+	// Insert the event and advance the chain head.
+	userName, userZone, subject := identityColumns(msg)
+	_, err = tx.ExecContext(
+		ctx, addEvent, permanentID(msg), msg.Path, eventType, msg.Timestamp.ToTime(), nodeID,
+		userName, userZone, subject, msg.Robot, nullString(msg.TimestampSource), nullString(msg.DatasetID),
+		nullString(msg.Role), e.Seq, nullString(previousHash), hash, nullInt64(msg.ArchiveID),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, updateChainHead, nodeID, e.Seq, hash)
+	return err
+}
+
+// recordEventMetrics updates the metrics that describe recorded events.
+func recordEventMetrics(eventType, handler string, msg *model.Message) {
+	metrics.EventsRecorded.WithLabelValues(eventType).Inc()
+	if handler == HandlerRead && msg.DatasetID != "" {
+		metrics.DatasetReads.Inc()
+	}
+	if handler == HandlerRead && msg.Role != "" {
+		metrics.ReadsByRole.WithLabelValues(msg.Role).Inc()
+	}
+	if msg.Timestamp != nil {
+		metrics.EventLag.Observe(time.Since(*msg.Timestamp.ToTime()).Seconds())
+	}
 }
 
-// buildHandlerMap builds a map from AMQP routing key to handler functions.
+// buildHandlerMap builds a map from AMQP routing key to handler functions. Handlers without routing keys are omitted.
 func buildHandlerMap(keyNames *KeyNames) *HandlerMap {
-	return &HandlerMap{
-		keyNames.Read: recordReadEvent,
+	handlers := HandlerMap{}
+	for routingKey, handler := range map[string]string{
+		keyNames.Read:   HandlerRead,
+		keyNames.Create: HandlerCreate,
+		keyNames.Update: HandlerUpdate,
+		keyNames.Delete: HandlerDelete,
+		keyNames.Move:   HandlerMove,
+	} {
+		if routingKey != "" {
+			handlers[routingKey] = handlerFunctions[handler]
+		}
 	}
+	return &handlers
 }
 
 // NewRecorder creates and returns a new DefaultRecorder object.
@@ -93,6 +414,11 @@ func (r DefaultRecorder) GetHandlerMap() *HandlerMap {
 }
 
 // RecordEvent records an event in the database if there is a handler for the given routing key.
-func (r DefaultRecorder) RecordEvent(key string, msg *model.Message) error {
-	return dispatchMessage(r, key, msg)
+func (r DefaultRecorder) RecordEvent(ctx context.Context, key string, msg *model.Message) error {
+	return dispatchMessage(ctx, r, key, msg)
+}
+
+// RecordHandlerEvent records an event in the database using the handler with the given name.
+func (r DefaultRecorder) RecordHandlerEvent(ctx context.Context, handler string, msg *model.Message) error {
+	return dispatchHandlerMessage(ctx, r, handler, msg)
 }
@@ -1,9 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/cyverse-de/dataone-indexer/model"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
@@ -11,13 +13,21 @@ import (
 
 // Routing keys to use for testing.
 const (
-	ReadKey = "data-object.open"
+	ReadKey   = "data-object.open"
+	CreateKey = "data-object.add"
+	UpdateKey = "data-object.mod"
+	DeleteKey = "data-object.rm"
+	MoveKey   = "data-object.mv"
 )
 
 // getKeyNames defines the structure describing which routing keys correspond to which types of events.
 func getKeyNames() *KeyNames {
 	return &KeyNames{
-		Read: ReadKey,
+		Read:   ReadKey,
+		Create: CreateKey,
+		Update: UpdateKey,
+		Delete: DeleteKey,
+		Move:   MoveKey,
 	}
 }
 
@@ -33,9 +43,22 @@ func getTestMessage() *model.Message {
 		Entity:    "F3579BF9-284B-4B3C-841B-F6E87D3F78EA",
 		Path:      "/iplant/home/shared/commons-repo/curated/foo.txt",
 		Timestamp: model.CurrentTimestamp(),
+		Identity: model.Identity{
+			UserName: "ipcdev",
+			UserZone: "iplant",
+			Subject:  "http://orcid.org/0000-0002-1825-0097",
+		},
+		TimestampSource: model.TimestampSourceMessage,
 	}
 }
 
+// expectChainHead describes the query used to lock the hash chain head for a node.
+func expectChainHead(mock sqlmock.Sqlmock, nodeID string, seq int64, hash string) {
+	mock.ExpectQuery("INSERT INTO event_chain_heads").
+		WithArgs(nodeID).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq", "last_hash"}).AddRow(seq, hash))
+}
+
 // TestReadEvent verifies that a read event can be recorded successfully.
 func TestReadEvent(t *testing.T) {
 
@@ -51,13 +74,19 @@ func TestReadEvent(t *testing.T) {
 
 	// Describe the expected database actions.
 	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
 	mock.ExpectExec("INSERT INTO event_log").
-		WithArgs(msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID()).
+		WithArgs(
+			msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, nil, nil,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	// Record the message.
-	if err := r.RecordEvent(ReadKey, msg); err != nil {
+	if err := r.RecordEvent(context.Background(), ReadKey, msg); err != nil {
 		t.Fatalf("error encountered while recording event: %s", err)
 	}
 
@@ -82,13 +111,18 @@ func TestErroneousReadEvent(t *testing.T) {
 
 	// Describe the expected database actions.
 	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
 	mock.ExpectExec("INSERT INTO event_log").
-		WithArgs(msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID()).
+		WithArgs(
+			msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, nil, nil,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
 		WillReturnError(fmt.Errorf("something bad happened"))
 	mock.ExpectRollback()
 
 	// Record the message.
-	if err := r.RecordEvent(ReadKey, msg); err == nil {
+	if err := r.RecordEvent(context.Background(), ReadKey, msg); err == nil {
 		t.Fatalf("an error was expected but none was encountered")
 	}
 
@@ -97,3 +131,342 @@ func TestErroneousReadEvent(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+// TestReadEventForNode verifies that read events are recorded for the node that owns the path, using any event type
+// overrides associated with the repository root.
+func TestReadEventForNode(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message.
+	r := getTestRecorder(db)
+	msg := getTestMessage()
+	msg.NodeID = "othernode"
+	msg.EventTypes = map[string]string{HandlerRead: ETReplicate}
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, "othernode", 0, "")
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			msg.Entity, msg.Path, ETReplicate, msg.Timestamp.ToTime(), "othernode", "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, nil, nil,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Record the message.
+	if err := r.RecordEvent(context.Background(), ReadKey, msg); err != nil {
+		t.Fatalf("error encountered while recording event: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestAnonymousReadEvent verifies that the user columns are NULL when a message has no author and that events caused
+// by robots are flagged.
+func TestAnonymousReadEvent(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message.
+	r := getTestRecorder(db)
+	msg := getTestMessage()
+	msg.Author = nil
+	msg.Identity = model.Identity{}
+	msg.Robot = true
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID(), nil, nil, nil, true,
+			model.TimestampSourceMessage, nil, nil,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Record the message.
+	if err := r.RecordEvent(context.Background(), ReadKey, msg); err != nil {
+		t.Fatalf("error encountered while recording event: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestPackageEvent verifies that reads of files within a dataset are attributed to the dataset and that a
+// package-level event is recorded for the dataset when requested.
+func TestPackageEvent(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message.
+	r := getTestRecorder(db)
+	msg := getTestMessage()
+	msg.DatasetPath = "/iplant/home/shared/commons-repo/curated/study"
+	msg.DatasetID = "doi:10.5072/FK2"
+	msg.PackageEvent = true
+	msg.Role = model.RoleData
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			msg.Entity, msg.Path, ETRead, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, msg.DatasetID, model.RoleData,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChainHead(mock, r.GetNodeID(), 1, "0123abcd")
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			msg.DatasetID, msg.DatasetPath, ETRead, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, msg.DatasetID, model.RoleResourceMap,
+			int64(2), "0123abcd", sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Record the message.
+	if err := r.RecordEvent(context.Background(), ReadKey, msg); err != nil {
+		t.Fatalf("error encountered while recording event: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestScrubIdentities verifies that user identifiers can be removed from old events.
+func TestScrubIdentities(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	cutoff := time.Now().Add(-24 * time.Hour)
+	mock.ExpectExec("UPDATE event_log SET user_name = NULL").
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	// Scrub the identities.
+	count, err := ScrubIdentities(context.Background(), db, cutoff)
+	if err != nil {
+		t.Fatalf("error encountered while scrubbing identities: %s", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 events to be scrubbed but got %d", count)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestEventTypes verifies that each handler records the expected event type and updates the inventory.
+func TestEventTypes(t *testing.T) {
+	expected := map[string]string{
+		CreateKey: ETCreate,
+		UpdateKey: ETUpdate,
+		DeleteKey: ETDelete,
+		MoveKey:   ETUpdate,
+	}
+	for key, eventType := range expected {
+
+		// Create the stub database connection.
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening stub database connection: %s", err)
+		}
+
+		// Prepare to record the message.
+		r := getTestRecorder(db)
+		msg := getTestMessage()
+		size := int64(1024)
+		msg.Size = &size
+		msg.Checksum = "sha2:1B2M2Y8AsgTpgAmY7PhCfg=="
+
+		// Describe the expected database actions.
+		mock.ExpectBegin()
+		expectChainHead(mock, r.GetNodeID(), 0, "")
+		mock.ExpectExec("INSERT INTO event_log").
+			WithArgs(
+				msg.Entity, msg.Path, eventType, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+				msg.Identity.Subject, false, model.TimestampSourceMessage, nil, nil,
+				int64(1), nil, sqlmock.AnyArg(), nil,
+			).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO inventory").
+			WithArgs(msg.Entity, nil, msg.Path, r.GetNodeID(), msg.Timestamp.ToTime(), size, msg.Checksum).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		// Record the message.
+		if err := r.RecordEvent(context.Background(), key, msg); err != nil {
+			t.Fatalf("%s: error encountered while recording event: %s", key, err)
+		}
+
+		// Verify that the expectations were met.
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: there were unfulfilled expectations: %s", key, err)
+		}
+	}
+}
+
+// TestDisabledHandlers verifies that handlers without routing keys are omitted from the handler map.
+func TestDisabledHandlers(t *testing.T) {
+	handlers := *buildHandlerMap(&KeyNames{Read: ReadKey})
+	if len(handlers) != 1 || handlers[ReadKey] == nil {
+		t.Errorf("unexpected handler map: %v", handlers)
+	}
+}
+
+// TestInventoryRollback verifies that the event isn't recorded if the inventory can't be updated.
+func TestInventoryRollback(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message.
+	r := getTestRecorder(db)
+	msg := getTestMessage()
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
+	mock.ExpectExec("INSERT INTO event_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO inventory").WillReturnError(fmt.Errorf("relation does not exist"))
+	mock.ExpectRollback()
+
+	// Attempt to record the message.
+	if err := r.RecordEvent(context.Background(), DeleteKey, msg); err == nil {
+		t.Error("an error was expected but none was returned")
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestHandlerEvent verifies that events can be recorded by handler name, without a routing key, and that the
+// inventory entry for a message without an entity ID is found by path.
+func TestHandlerEvent(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message. The update handler doesn't have a routing key.
+	r := NewRecorder(db, &KeyNames{Read: ReadKey}, "fakenode")
+	msg := getTestMessage()
+	msg.Entity = ""
+	msg.PID = "doi:10.5072/FK2TEST"
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			msg.PID, msg.Path, ETUpdate, msg.Timestamp.ToTime(), r.GetNodeID(), "ipcdev", "iplant",
+			msg.Identity.Subject, false, model.TimestampSourceMessage, nil, nil,
+			int64(1), nil, sqlmock.AnyArg(), nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT irods_uuid FROM inventory").
+		WithArgs(msg.Path).
+		WillReturnRows(sqlmock.NewRows([]string{"irods_uuid"}).AddRow("F3579BF9-284B-4B3C-841B-F6E87D3F78EA"))
+	mock.ExpectExec("INSERT INTO inventory").
+		WithArgs(
+			"F3579BF9-284B-4B3C-841B-F6E87D3F78EA", msg.PID, msg.Path, r.GetNodeID(), msg.Timestamp.ToTime(), nil, nil,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// Record the message.
+	if err := r.RecordHandlerEvent(context.Background(), HandlerUpdate, msg); err != nil {
+		t.Fatalf("error encountered while recording event: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestUnknownInventoryItem verifies that the inventory isn't updated for a message without an entity ID if the object
+// isn't in the inventory. Moves are looked up by their source paths.
+func TestUnknownInventoryItem(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Prepare to record the message.
+	r := getTestRecorder(db)
+	msg := getTestMessage()
+	msg.Entity = ""
+	msg.PID = "doi:10.5072/FK2TEST"
+	msg.SourcePath = "/iplant/home/shared/commons-repo/curated/bar.txt"
+
+	// Describe the expected database actions.
+	mock.ExpectBegin()
+	expectChainHead(mock, r.GetNodeID(), 0, "")
+	mock.ExpectExec("INSERT INTO event_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT irods_uuid FROM inventory").
+		WithArgs(msg.SourcePath).
+		WillReturnRows(sqlmock.NewRows([]string{"irods_uuid"}))
+	mock.ExpectCommit()
+
+	// Record the message.
+	if err := r.RecordHandlerEvent(context.Background(), HandlerMove, msg); err != nil {
+		t.Fatalf("error encountered while recording event: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
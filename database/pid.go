@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+
+	"github.com/cyverse-de/dataone-indexer/metrics"
+)
+
+// tableNamePattern matches table names that may optionally be qualified with a schema name.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// IsTableName returns true if a string is a valid, optionally schema qualified, table name.
+func IsTableName(name string) bool {
+	return tableNamePattern.MatchString(name)
+}
+
+// PIDTable looks up persistent identifiers in a mapping table with irods_uuid, irods_path and pid columns.
+type PIDTable struct {
+	db    *sql.DB
+	query string
+}
+
+// NewPIDTable creates a new PIDTable for the mapping table with the given name.
+func NewPIDTable(db *sql.DB, table string) (*PIDTable, error) {
+	if !IsTableName(table) {
+		return nil, fmt.Errorf("invalid table name: %s", table)
+	}
+	return &PIDTable{db: db, query: fmt.Sprintf(lookupPID, table)}, nil
+}
+
+// LookupPID returns the persistent identifier and UUID for an object with a UUID or path. Empty strings are returned
+// if the object isn't in the mapping table.
+func (t *PIDTable) LookupPID(ctx context.Context, uuid, path string) (string, string, error) {
+	var pid string
+	var mappedUUID sql.NullString
+	err := t.db.QueryRowContext(ctx, t.query, uuid, path).Scan(&pid, &mappedUUID)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", "", nil
+	case err != nil:
+		metrics.DatabaseErrors.Inc()
+		return "", "", err
+	default:
+		return pid, mappedUUID.String, nil
+	}
+}
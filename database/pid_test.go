@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// TestLookupPID verifies that persistent identifiers are looked up in the mapping table.
+func TestLookupPID(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+
+	// Describe the expected database actions.
+	mock.ExpectQuery("SELECT pid, irods_uuid FROM pid_mapping").
+		WithArgs("uuid1", "/path/1").
+		WillReturnRows(sqlmock.NewRows([]string{"pid", "irods_uuid"}).AddRow("doi:10.5072/FK2", "uuid1"))
+	mock.ExpectQuery("SELECT pid, irods_uuid FROM pid_mapping").
+		WithArgs("uuid2", "/path/2").
+		WillReturnRows(sqlmock.NewRows([]string{"pid", "irods_uuid"}))
+
+	// Look up the identifiers.
+	table, err := NewPIDTable(db, "pid_mapping")
+	if err != nil {
+		t.Fatalf("unable to create the PID table: %s", err)
+	}
+	pid, uuid, err := table.LookupPID(context.Background(), "uuid1", "/path/1")
+	if err != nil || pid != "doi:10.5072/FK2" || uuid != "uuid1" {
+		t.Errorf("unexpected result: %s, %s, %v", pid, uuid, err)
+	}
+	if pid, uuid, err = table.LookupPID(context.Background(), "uuid2", "/path/2"); err != nil || pid != "" || uuid != "" {
+		t.Errorf("unexpected result: %s, %s, %v", pid, uuid, err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// TestInvalidTableName verifies that table names that could be used for SQL injection are rejected.
+func TestInvalidTableName(t *testing.T) {
+	if _, err := NewPIDTable(nil, "pid_mapping; DROP TABLE event_log"); err == nil {
+		t.Error("an error was expected but none was returned")
+	}
+}
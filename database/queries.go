@@ -11,8 +11,208 @@ const (
 	ETUpdate                = "UPDATE"
 )
 
+// EventTypes lists every supported event type.
+var EventTypes = []string{
+	ETCreate, ETDelete, ETRead, ETReplicate, ETReplicationFailed, ETSynchronizationFailed, ETUpdate,
+}
+
+// IsEventType returns true if a string is a supported event type.
+func IsEventType(eventType string) bool {
+	for _, et := range EventTypes {
+		if et == eventType {
+			return true
+		}
+	}
+	return false
+}
+
 // The statement used to add an event to the database.
 const addEvent = `
-INSERT INTO event_log (permanent_id, irods_path, event, date_logged, node_identifier)
-VALUES ($1, $2, $3, $4, $5);
+INSERT INTO event_log (
+    permanent_id, irods_path, event, date_logged, node_identifier, user_name, user_zone, subject, robot,
+    timestamp_source, dataset_id, root_role, chain_seq, previous_hash, row_hash, archive_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16);
+`
+
+// The statement used to lock the hash chain head for a node and obtain the sequence number and hash of the last event.
+// The head is created if the node doesn't have one yet.
+const lockChainHead = `
+INSERT INTO event_chain_heads (node_identifier, last_seq, last_hash)
+VALUES ($1, 0, '')
+ON CONFLICT (node_identifier) DO UPDATE SET last_seq = event_chain_heads.last_seq
+RETURNING last_seq, last_hash;
+`
+
+// The statement used to advance the hash chain head for a node.
+const updateChainHead = `
+UPDATE event_chain_heads SET last_seq = $2, last_hash = $3
+WHERE node_identifier = $1;
+`
+
+// The query used to obtain the hash chain head for a node without locking it.
+const getChainHead = `
+SELECT last_seq, last_hash FROM event_chain_heads
+WHERE node_identifier = $1;
+`
+
+// The query used to list the nodes that have hash chains.
+const listChainNodes = `
+SELECT node_identifier FROM event_chain_heads
+ORDER BY node_identifier;
+`
+
+// The query used to list the chained events for a node in chain order.
+const listChainedEvents = `
+SELECT chain_seq, previous_hash, permanent_id, irods_path, event, date_logged, node_identifier, robot,
+    timestamp_source, dataset_id, root_role, row_hash
+FROM event_log
+WHERE node_identifier = $1 AND chain_seq IS NOT NULL
+ORDER BY chain_seq;
+`
+
+// The statement used to remove user identifiers from events logged before a cutoff time.
+const scrubIdentities = `
+UPDATE event_log SET user_name = NULL, user_zone = NULL, subject = NULL
+WHERE date_logged < $1
+AND (user_name IS NOT NULL OR user_zone IS NOT NULL OR subject IS NOT NULL);
+`
+
+// The statement used to record the hash chain positions of events that are about to be deleted by a user erasure. The
+// request ID placeholder number, table name and selection condition are substituted when the statement is built.
+const addErasedEvents = `
+INSERT INTO erased_events (node_identifier, chain_seq, previous_hash, row_hash, request_id, erased_at)
+SELECT node_identifier, chain_seq, previous_hash, row_hash, $%d, now() FROM %s
+WHERE chain_seq IS NOT NULL AND (%s);
+`
+
+// The query used to list the hash chain positions of the events deleted by user erasures for a node.
+const listErasedEvents = `
+SELECT chain_seq, previous_hash, row_hash FROM erased_events
+WHERE node_identifier = $1
+ORDER BY chain_seq;
+`
+
+// The statement used to record the changes made when a user's events are erased.
+const addErasureAudit = `
+INSERT INTO erasure_audit (
+    erased_at, action, criteria, table_name, rows_affected, requested_by, reference, request_id
+)
+VALUES (now(), $1, $2, $3, $4, $5, $6, $7);
+`
+
+// The statement used to claim the read deduplication window for a key. A row is only returned if the key hasn't been
+// claimed or the previous claim is at least a full window away from the new one in either direction. Events may arrive
+// out of order, so the comparison matches the one used by the in-memory cache.
+const claimDedupKey = `
+INSERT INTO read_dedup (dedup_key, last_claimed) VALUES ($1, $2)
+ON CONFLICT (dedup_key) DO UPDATE SET last_claimed = EXCLUDED.last_claimed
+WHERE abs(extract(epoch FROM EXCLUDED.last_claimed - read_dedup.last_claimed)) >= $3
+RETURNING dedup_key;
+`
+
+// The statement used to remove read deduplication claims that have expired. Claims are recorded at the time of the
+// event rather than the time it was processed, so claims expire once they're more than a window older than the most
+// recent claim.
+const pruneDedupKeys = `
+DELETE FROM read_dedup
+WHERE last_claimed < (SELECT max(last_claimed) FROM read_dedup) - $1 * INTERVAL '1 second';
+`
+
+// The statement used to add an object to the inventory. An object that was previously deleted is restored with a new
+// creation date.
+const createInventoryItem = `
+INSERT INTO inventory (
+    irods_uuid, permanent_id, irods_path, node_identifier, date_created, date_modified, size, checksum
+)
+VALUES ($1, $2, $3, $4, $5, $5, $6, $7)
+ON CONFLICT (irods_uuid) DO UPDATE SET
+    permanent_id = COALESCE(EXCLUDED.permanent_id, inventory.permanent_id),
+    irods_path = EXCLUDED.irods_path,
+    node_identifier = EXCLUDED.node_identifier,
+    date_created = CASE WHEN inventory.date_deleted IS NULL
+        THEN COALESCE(inventory.date_created, EXCLUDED.date_created)
+        ELSE EXCLUDED.date_created END,
+    date_modified = GREATEST(inventory.date_modified, EXCLUDED.date_modified),
+    date_deleted = NULL,
+    size = COALESCE(EXCLUDED.size, inventory.size),
+    checksum = COALESCE(EXCLUDED.checksum, inventory.checksum);
+`
+
+// The statement used to record a modification to an object, including a move. Objects that were created before the
+// inventory was introduced are added with an unknown creation date. The persistent identifier, size and checksum are
+// only replaced if the message reports them.
+const updateInventoryItem = `
+INSERT INTO inventory (irods_uuid, permanent_id, irods_path, node_identifier, date_modified, size, checksum)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (irods_uuid) DO UPDATE SET
+    permanent_id = COALESCE(EXCLUDED.permanent_id, inventory.permanent_id),
+    irods_path = EXCLUDED.irods_path,
+    node_identifier = EXCLUDED.node_identifier,
+    date_modified = GREATEST(inventory.date_modified, EXCLUDED.date_modified),
+    size = COALESCE(EXCLUDED.size, inventory.size),
+    checksum = COALESCE(EXCLUDED.checksum, inventory.checksum);
+`
+
+// The statement used to record the deletion of an object. The last known size and checksum are retained.
+const deleteInventoryItem = `
+INSERT INTO inventory (
+    irods_uuid, permanent_id, irods_path, node_identifier, date_modified, date_deleted, size, checksum
+)
+VALUES ($1, $2, $3, $4, $5, $5, $6, $7)
+ON CONFLICT (irods_uuid) DO UPDATE SET
+    permanent_id = COALESCE(EXCLUDED.permanent_id, inventory.permanent_id),
+    date_modified = GREATEST(inventory.date_modified, EXCLUDED.date_modified),
+    date_deleted = EXCLUDED.date_deleted;
+`
+
+// The query used to find the UUID of the live object at a path in the inventory. It's used for messages that don't
+// contain entity IDs.
+const findInventoryUUID = `
+SELECT irods_uuid FROM inventory
+WHERE irods_path = $1 AND date_deleted IS NULL
+LIMIT 1;
+`
+
+// The query used to look up a persistent identifier and UUID by UUID or path. The table name is substituted when the query is
+// prepared; matches by UUID take precedence over matches by path.
+const lookupPID = `
+SELECT pid, irods_uuid FROM %s
+WHERE irods_uuid = $1 OR irods_path = $2
+ORDER BY irods_uuid = $1 DESC
+LIMIT 1;
+`
+
+// The statement used to add a message to the raw message archive.
+const addArchivedMessage = `
+INSERT INTO message_archive (
+    received_at, routing_key, content_type, schema_version, delivery_time, body, user_name, user_zone, subject
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id;
+`
+
+// The query used to list the archived messages received within a time range.
+const listArchivedMessages = `
+SELECT id, received_at, routing_key, content_type, schema_version, delivery_time, body
+FROM message_archive
+WHERE received_at >= $1 AND received_at < $2
+ORDER BY received_at, id;
+`
+
+// The query used to determine whether any events have been recorded for an archived message.
+const archivedMessageRecorded = `
+SELECT EXISTS (SELECT 1 FROM event_log WHERE archive_id = $1);
+`
+
+// The statement used to remove the bodies and user identifiers of archived messages received before a cutoff time.
+const scrubArchivedMessages = `
+UPDATE message_archive SET body = NULL, user_name = NULL, user_zone = NULL, subject = NULL
+WHERE received_at < $1
+AND (body IS NOT NULL OR user_name IS NOT NULL OR user_zone IS NOT NULL OR subject IS NOT NULL);
+`
+
+// The statement used to remove archived messages received before a cutoff time.
+const pruneArchivedMessages = `
+DELETE FROM message_archive WHERE received_at < $1;
 `
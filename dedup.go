@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/config"
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/dedup"
+	"github.com/cyverse-de/dataone-indexer/logger"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/spf13/viper"
+)
+
+// dedupPruneInterval is the amount of time between removals of expired deduplication claims from the database.
+const dedupPruneInterval = 10 * time.Minute
+
+// getDedupStore returns the store used to share read deduplication claims through the database, or nil if dedup.shared
+// isn't set. Claims are hashed with the current identity key.
+func getDedupStore(cfg *viper.Viper, db *sql.DB) (*database.DedupStore, error) {
+	if !cfg.GetBool("dedup.shared") {
+		return nil, nil
+	}
+	keys, err := config.IdentityKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("an identity key is required to share deduplication claims")
+	}
+	return database.NewDedupStore(db, keys[0]), nil
+}
+
+// getDeduplicator builds the deduplicator used to collapse repeated reads. Claims are shared through the database if
+// a store is provided.
+func getDeduplicator(cfg *viper.Viper, db *database.DedupStore) *dedup.Deduplicator {
+	var store dedup.Store
+	if db != nil {
+		store = db
+	}
+	return dedup.NewDeduplicator(cfg.GetDuration("dedup.window"), cfg.GetInt("dedup.max-entries"), store)
+}
+
+// isDuplicateRead returns true if a read event duplicates an earlier read of the same entity by the same user. The
+// event is treated as a non-duplicate if the shared claims can't be checked.
+func (svc *DataoneIndexer) isDuplicateRead(ctx context.Context, msg *model.Message) bool {
+	// Messages from the iRODS audit plugin don't contain entity IDs, so the path is used instead.
+	entity := msg.Entity
+	if entity == "" {
+		entity = msg.Path
+	}
+	return svc.isDuplicate(ctx, entity, msg)
+}
+
+// isDuplicatePackageRead returns true if a package-level read event duplicates an earlier read of any file in the same
+// dataset by the same user. Reading several files in a dataset within the window only produces one package event.
+func (svc *DataoneIndexer) isDuplicatePackageRead(ctx context.Context, msg *model.Message) bool {
+	return svc.isDuplicate(ctx, "dataset:"+msg.DatasetID, msg)
+}
+
+// isDuplicate returns true if an event for an entity duplicates an earlier event for the same entity by the same user.
+func (svc *DataoneIndexer) isDuplicate(ctx context.Context, entity string, msg *model.Message) bool {
+	duplicate, err := svc.dedup.Duplicate(ctx, dedup.Key(entity, msg.Author), *msg.Timestamp.ToTime())
+	if err != nil {
+		logger.Log.Warnf("unable to check for duplicate reads: %s", err)
+	}
+	return duplicate
+}
+
+// pruneDedupClaims periodically removes expired deduplication claims from the database.
+func (svc *DataoneIndexer) pruneDedupClaims(store *database.DedupStore) {
+	ticker := time.NewTicker(dedupPruneInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := store.Prune(context.Background(), svc.dedup.Window()); err != nil {
+			logger.Log.Errorf("unable to remove expired deduplication claims: %s", err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Package dedup collapses repeated read events for the same entity and user that occur within a short window.
+//
+// A single download frequently generates several read messages a few seconds apart. The first read for an entity and
+// user claims the window, and any further reads for the same entity and user within the window are reported as
+// duplicates. Claims are tracked in a bounded in-memory cache, which evicts the least recently used entries when it's
+// full. Claims may also be shared with other replicas through a Store, which is consulted whenever the local cache
+// doesn't already contain a claim.
+package dedup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/lru"
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// Store is implemented by anything that can record claims shared by multiple replicas.
+type Store interface {
+
+	// Claim attempts to claim the window for a key at a time. It returns true if the claim succeeds, meaning that
+	// the key hasn't been claimed within the window of that time in either direction. Implementations must use the
+	// same comparison as the in-memory cache.
+	Claim(ctx context.Context, key string, t time.Time, window time.Duration) (bool, error)
+}
+
+// Key returns the deduplication key for an event on an entity caused by a user.
+func Key(entity string, user *model.User) string {
+	if user == nil {
+		return entity
+	}
+	return entity + "|" + user.Name + "#" + user.Zone
+}
+
+// Deduplicator determines whether or not events are duplicates.
+type Deduplicator struct {
+	window time.Duration
+	store  Store
+
+	// mu serializes claims so that checking and updating the claim time for a key is atomic.
+	mu     sync.Mutex
+	claims *lru.Cache
+}
+
+// NewDeduplicator creates a new Deduplicator. Deduplication is disabled if the window isn't positive. The store is
+// optional; claims are only tracked in memory if it's nil.
+func NewDeduplicator(window time.Duration, maxEntries int, store Store) *Deduplicator {
+	return &Deduplicator{
+		window: window,
+		store:  store,
+		claims: lru.New(maxEntries),
+	}
+}
+
+// Window returns the deduplication window.
+func (d *Deduplicator) Window() time.Duration {
+	return d.window
+}
+
+// within returns true if two times are closer together than the window. Events may arrive out of order, so the order
+// of the times doesn't matter.
+func (d *Deduplicator) within(t1, t2 time.Time) bool {
+	delta := t1.Sub(t2)
+	if delta < 0 {
+		delta = -delta
+	}
+	return delta < d.window
+}
+
+// claimLocal attempts to claim the window for a key in the in-memory cache.
+func (d *Deduplicator) claimLocal(key string, t time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := d.claims.Get(key); ok && d.within(t, last.(time.Time)) {
+		return false
+	}
+	d.claims.Add(key, t)
+	return true
+}
+
+// Len returns the number of entries in the in-memory cache.
+func (d *Deduplicator) Len() int {
+	return d.claims.Len()
+}
+
+// Duplicate returns true if an event with a key at a time is a duplicate of an earlier event. The event claims the
+// window for the key if it isn't a duplicate. If the shared store returns an error, the error is returned and the
+// event should be treated as a non-duplicate.
+func (d *Deduplicator) Duplicate(ctx context.Context, key string, t time.Time) (bool, error) {
+	if d.window <= 0 {
+		return false, nil
+	}
+	if !d.claimLocal(key, t) {
+		return true, nil
+	}
+	if d.store == nil {
+		return false, nil
+	}
+	claimed, err := d.store.Claim(ctx, key, t, d.window)
+	if err != nil {
+		return false, err
+	}
+	return !claimed, nil
+}
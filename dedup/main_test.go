@@ -0,0 +1,112 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// fakeStore is a Store that records claims in a map.
+type fakeStore struct {
+	claims map[string]time.Time
+	err    error
+}
+
+// Claim attempts to claim the window for a key at a time.
+func (s *fakeStore) Claim(ctx context.Context, key string, t time.Time, window time.Duration) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	if last, ok := s.claims[key]; ok && t.Sub(last) < window {
+		return false, nil
+	}
+	s.claims[key] = t
+	return true, nil
+}
+
+// duplicate calls Duplicate and fails the test if an error is returned.
+func duplicate(t *testing.T, d *Deduplicator, key string, ts time.Time) bool {
+	result, err := d.Duplicate(context.Background(), key, ts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return result
+}
+
+// TestWindow verifies that repeated events are only reported as duplicates within the window, including events that
+// arrive out of order.
+func TestWindow(t *testing.T) {
+	d := NewDeduplicator(30*time.Second, 10, nil)
+	start := time.Now()
+	key := Key("entity", &model.User{Name: "alice", Zone: "iplant"})
+
+	expected := []struct {
+		offset    time.Duration
+		duplicate bool
+	}{
+		{0, false},
+		{5 * time.Second, true},
+		{29 * time.Second, true},
+		{30 * time.Second, false},
+		{45 * time.Second, true},
+		{61 * time.Second, false},
+		{40 * time.Second, true},
+	}
+	for _, tc := range expected {
+		if result := duplicate(t, d, key, start.Add(tc.offset)); result != tc.duplicate {
+			t.Errorf("offset %s: expected duplicate=%t but got %t", tc.offset, tc.duplicate, result)
+		}
+	}
+
+	if duplicate(t, d, Key("entity", &model.User{Name: "bob", Zone: "iplant"}), start.Add(62*time.Second)) {
+		t.Error("an event caused by a different user was reported as a duplicate")
+	}
+}
+
+// TestDisabled verifies that nothing is reported as a duplicate if the window isn't positive.
+func TestDisabled(t *testing.T) {
+	d := NewDeduplicator(0, 10, nil)
+	now := time.Now()
+	if duplicate(t, d, "key", now) || duplicate(t, d, "key", now) {
+		t.Error("an event was reported as a duplicate with deduplication disabled")
+	}
+}
+
+// TestEviction verifies that the in-memory cache is bounded.
+func TestEviction(t *testing.T) {
+	d := NewDeduplicator(time.Minute, 2, nil)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		duplicate(t, d, fmt.Sprintf("key%d", i), now)
+	}
+	if d.Len() != 2 {
+		t.Errorf("expected 2 cache entries but got %d", d.Len())
+	}
+	if duplicate(t, d, "key0", now) {
+		t.Error("an evicted entry was reported as a duplicate")
+	}
+	if !duplicate(t, d, "key2", now) {
+		t.Error("a cached entry was not reported as a duplicate")
+	}
+}
+
+// TestSharedStore verifies that claims made by other replicas are honored.
+func TestSharedStore(t *testing.T) {
+	now := time.Now()
+	store := &fakeStore{claims: map[string]time.Time{"key": now.Add(-10 * time.Second)}}
+	d := NewDeduplicator(30*time.Second, 10, store)
+	if !duplicate(t, d, "key", now) {
+		t.Error("an event claimed by another replica was not reported as a duplicate")
+	}
+	if duplicate(t, d, "other", now) {
+		t.Error("an unclaimed event was reported as a duplicate")
+	}
+
+	store.err = fmt.Errorf("connection refused")
+	if _, err := d.Duplicate(context.Background(), "third", now); err == nil {
+		t.Error("expected the store error to be returned")
+	}
+}
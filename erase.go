@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/spf13/viper"
+)
+
+// newRequestID generates an opaque identifier for an erasure request.
+func newRequestID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("unable to generate a request ID: %s", err)
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// buildErasureRequest builds the request used to erase a user's events from the command-line options. The stored
+// forms of each identifier are computed using every configured identity key so that events recorded before the keys
+// were rotated are found as well.
+func buildErasureRequest(cfg *viper.Viper, user, subject string) (*database.ErasureRequest, error) {
+	if user == "" && subject == "" {
+		return nil, fmt.Errorf("a user or subject is required")
+	}
+
+	identities, err := getPseudonymizer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	requestID, err := newRequestID()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &database.ErasureRequest{
+		Action:      *eraseUserAction,
+		RequestID:   requestID,
+		RequestedBy: *eraseUserRequestedBy,
+		Reference:   *eraseUserReference,
+	}
+
+	// The audit record refers to the user by keyed hash rather than by name. Only the type of identifier is recorded
+	// if there are no identity keys.
+	var criteria []string
+	if user != "" {
+		parts := strings.SplitN(user, "#", 2)
+		req.UserNames = identities.StoredForms(parts[0])
+		if len(parts) > 1 {
+			req.UserZone = parts[1]
+		}
+		criteria = append(criteria, strings.TrimSpace("user "+identities.Reference(user)))
+	}
+	if subject != "" {
+		req.Subjects = identities.StoredForms(subject)
+		criteria = append(criteria, strings.TrimSpace("subject "+identities.Reference(subject)))
+	}
+	req.Criteria = strings.Join(criteria, " or ")
+
+	return req, nil
+}
+
+// eraseUser deletes or anonymizes every event tied to the user or subject specified on the command line.
+func eraseUser(cfg *viper.Viper) {
+	req, err := buildErasureRequest(cfg, *eraseUserUser, *eraseUserSubject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to erase the user: %s\n", err)
+		os.Exit(1)
+	}
+
+	db, err := getDbConnection(cfg.GetString("db.uri"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to establish the database connection: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	results, err := database.EraseUser(context.Background(), db, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to erase the user: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("erasure request %s\n", req.RequestID)
+	for _, result := range results {
+		fmt.Printf("%s: %s %d row(s)\n", result.Table, req.Action, result.RowsAffected)
+	}
+}
@@ -0,0 +1,123 @@
+// Package exclusion determines whether or not events should be ignored because of the user who caused them.
+//
+// Events caused by service accounts, curation scripts and backup jobs inflate usage statistics, so they can be
+// excluded by user name, by zone or by group membership. User names may be qualified with a zone using the iRODS
+// convention, name#zone, in which case only the user in that zone is excluded. Group memberships are read from a
+// local YAML or JSON file containing a map from group name to a list of user names.
+package exclusion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Exclusion reasons.
+const (
+	ReasonUser  = "user"
+	ReasonZone  = "zone"
+	ReasonGroup = "group"
+)
+
+// userSet represents a set of users that may or may not be qualified with a zone.
+type userSet map[string]bool
+
+// add adds a user to the set.
+func (s userSet) add(user string) {
+	s[strings.TrimSpace(user)] = true
+}
+
+// contains returns true if a user is in the set, either by qualified name or by unqualified name.
+func (s userSet) contains(user *model.User) bool {
+	return s[user.Name] || s[user.Name+"#"+user.Zone]
+}
+
+// Excluder determines whether or not events caused by a user should be excluded.
+type Excluder struct {
+	users     userSet
+	zones     map[string]bool
+	groupSets map[string]userSet
+}
+
+// Options describes the users, zones and groups to exclude.
+type Options struct {
+	Users  []string
+	Zones  []string
+	Groups []string
+
+	// GroupMembershipFile is the path to the file that maps group names to members. Group exclusions are ignored
+	// if this is empty.
+	GroupMembershipFile string
+}
+
+// LoadGroupMemberships loads a map from group name to list of members from a YAML or JSON file.
+func LoadGroupMemberships(path string) (map[string][]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberships map[string][]string
+	if err := yaml.Unmarshal(contents, &memberships); err != nil {
+		return nil, fmt.Errorf("unable to parse the group membership file %s: %s", path, err)
+	}
+	return memberships, nil
+}
+
+// NewExcluder creates a new Excluder.
+func NewExcluder(opts *Options) (*Excluder, error) {
+	e := &Excluder{
+		users:     make(userSet),
+		zones:     make(map[string]bool),
+		groupSets: make(map[string]userSet),
+	}
+
+	for _, user := range opts.Users {
+		e.users.add(user)
+	}
+	for _, zone := range opts.Zones {
+		e.zones[strings.TrimSpace(zone)] = true
+	}
+
+	// Load the group memberships if we need them.
+	if len(opts.Groups) > 0 && opts.GroupMembershipFile != "" {
+		memberships, err := LoadGroupMemberships(opts.GroupMembershipFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range opts.Groups {
+			members := make(userSet)
+			for _, member := range memberships[group] {
+				members.add(member)
+			}
+			e.groupSets[group] = members
+		}
+	}
+
+	return e, nil
+}
+
+// Excluded determines whether or not events caused by a user should be excluded. If so, the reason for the exclusion
+// is returned as well. Events without an author are never excluded.
+func (e *Excluder) Excluded(user *model.User) (string, bool) {
+	if user == nil {
+		return "", false
+	}
+
+	if e.users.contains(user) {
+		return ReasonUser, true
+	}
+	if e.zones[user.Zone] {
+		return ReasonZone, true
+	}
+	for _, members := range e.groupSets {
+		if members.contains(user) {
+			return ReasonGroup, true
+		}
+	}
+
+	return "", false
+}
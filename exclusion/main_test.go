@@ -0,0 +1,77 @@
+package exclusion
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// The contents of the group membership file used for testing.
+const testMemberships = `
+backup-jobs:
+  - backup
+  - archiver#otherzone
+curators:
+  - alice
+`
+
+// writeMemberships writes the group membership file used for testing and returns its path.
+func writeMemberships(t *testing.T) string {
+	f, err := ioutil.TempFile("", "memberships")
+	if err != nil {
+		t.Fatalf("unable to create the group membership file: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(testMemberships); err != nil {
+		t.Fatalf("unable to write the group membership file: %s", err)
+	}
+	return f.Name()
+}
+
+// TestExclusions verifies that users are excluded by name, zone and group.
+func TestExclusions(t *testing.T) {
+	path := writeMemberships(t)
+	defer os.Remove(path)
+
+	e, err := NewExcluder(&Options{
+		Users:               []string{"ipcdev", "curator#iplant"},
+		Zones:               []string{"internal"},
+		Groups:              []string{"backup-jobs"},
+		GroupMembershipFile: path,
+	})
+	if err != nil {
+		t.Fatalf("unable to create the excluder: %s", err)
+	}
+
+	expected := []struct {
+		user   *model.User
+		reason string
+	}{
+		{&model.User{Name: "ipcdev", Zone: "iplant"}, ReasonUser},
+		{&model.User{Name: "ipcdev", Zone: "otherzone"}, ReasonUser},
+		{&model.User{Name: "curator", Zone: "iplant"}, ReasonUser},
+		{&model.User{Name: "curator", Zone: "otherzone"}, ""},
+		{&model.User{Name: "someone", Zone: "internal"}, ReasonZone},
+		{&model.User{Name: "backup", Zone: "iplant"}, ReasonGroup},
+		{&model.User{Name: "archiver", Zone: "otherzone"}, ReasonGroup},
+		{&model.User{Name: "archiver", Zone: "iplant"}, ""},
+		{&model.User{Name: "alice", Zone: "iplant"}, ""},
+		{nil, ""},
+	}
+	for _, tc := range expected {
+		reason, excluded := e.Excluded(tc.user)
+		if excluded != (tc.reason != "") || reason != tc.reason {
+			t.Errorf("Excluded(%+v): expected reason %q but got %q", tc.user, tc.reason, reason)
+		}
+	}
+}
+
+// TestMissingMembershipFile verifies that an error is returned when the group membership file can't be read.
+func TestMissingMembershipFile(t *testing.T) {
+	_, err := NewExcluder(&Options{Groups: []string{"curators"}, GroupMembershipFile: "/nonexistent/file.yml"})
+	if err == nil {
+		t.Error("an error was expected but none was returned")
+	}
+}
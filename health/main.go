@@ -0,0 +1,107 @@
+// Package health tracks the state that the DataONE indexer reports through its liveness and readiness endpoints.
+//
+// The service is ready when it's attached to the AMQP queue as a consumer and the event database responds to pings.
+// The service is live as long as the message processing loop has reported a heartbeat recently. The processing loop
+// reports a heartbeat periodically even when it's idle, so a stale heartbeat indicates that the loop is wedged.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is implemented by anything that can be pinged to verify that it's available, such as a database connection.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Checker keeps track of the state used to determine whether the service is live and ready.
+type Checker struct {
+	db               Pinger
+	pingTimeout      time.Duration
+	maxHeartbeatAge  time.Duration
+	consumerAttached int32
+	lastHeartbeat    int64
+}
+
+// NewChecker creates and returns a new Checker. The heartbeat is initialized to the current time so that the service
+// is considered live while it's starting up.
+func NewChecker(db Pinger, pingTimeout, maxHeartbeatAge time.Duration) *Checker {
+	return &Checker{
+		db:              db,
+		pingTimeout:     pingTimeout,
+		maxHeartbeatAge: maxHeartbeatAge,
+		lastHeartbeat:   time.Now().UnixNano(),
+	}
+}
+
+// SetConsumerAttached records whether or not the service is currently consuming messages from the AMQP queue.
+func (c *Checker) SetConsumerAttached(attached bool) {
+	var value int32
+	if attached {
+		value = 1
+	}
+	atomic.StoreInt32(&c.consumerAttached, value)
+}
+
+// ConsumerAttached returns true if the service is currently consuming messages from the AMQP queue.
+func (c *Checker) ConsumerAttached() bool {
+	return atomic.LoadInt32(&c.consumerAttached) == 1
+}
+
+// Heartbeat records that the message processing loop is still making progress.
+func (c *Checker) Heartbeat() {
+	atomic.StoreInt64(&c.lastHeartbeat, time.Now().UnixNano())
+}
+
+// heartbeatAge returns the amount of time since the most recent heartbeat.
+func (c *Checker) heartbeatAge() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&c.lastHeartbeat)))
+}
+
+// Live returns an error if the message processing loop hasn't reported a heartbeat recently enough.
+func (c *Checker) Live() error {
+	if age := c.heartbeatAge(); age > c.maxHeartbeatAge {
+		return fmt.Errorf("no heartbeat from the message processing loop in %s", age)
+	}
+	return nil
+}
+
+// Ready returns an error if the service isn't consuming AMQP messages or if the database can't be reached.
+func (c *Checker) Ready() error {
+	if !c.ConsumerAttached() {
+		return fmt.Errorf("the AMQP consumer is not attached")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.pingTimeout)
+	defer cancel()
+	if err := c.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("unable to ping the database: %s", err)
+	}
+
+	return nil
+}
+
+// respond writes the response for a health check to an HTTP response writer.
+func respond(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, err)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// LivenessHandler responds to liveness probes.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	respond(w, c.Live())
+}
+
+// ReadinessHandler responds to readiness probes.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	respond(w, c.Ready())
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakePinger is a Pinger that returns a predetermined error.
+type fakePinger struct {
+	err error
+}
+
+// PingContext returns the error associated with the fake pinger.
+func (p fakePinger) PingContext(ctx context.Context) error {
+	return p.err
+}
+
+// TestNotReadyWithoutConsumer verifies that the service isn't ready until the AMQP consumer is attached.
+func TestNotReadyWithoutConsumer(t *testing.T) {
+	c := NewChecker(fakePinger{}, time.Second, time.Minute)
+	if err := c.Ready(); err == nil {
+		t.Error("the service should not be ready before the consumer is attached")
+	}
+
+	c.SetConsumerAttached(true)
+	if err := c.Ready(); err != nil {
+		t.Errorf("unexpected readiness error: %s", err)
+	}
+
+	c.SetConsumerAttached(false)
+	if err := c.Ready(); err == nil {
+		t.Error("the service should not be ready after the consumer is detached")
+	}
+}
+
+// TestNotReadyWithoutDatabase verifies that the service isn't ready when the database can't be pinged.
+func TestNotReadyWithoutDatabase(t *testing.T) {
+	c := NewChecker(fakePinger{err: fmt.Errorf("connection refused")}, time.Second, time.Minute)
+	c.SetConsumerAttached(true)
+	if err := c.Ready(); err == nil {
+		t.Error("the service should not be ready when the database is unavailable")
+	}
+}
+
+// TestStaleHeartbeat verifies that the liveness check fails when the heartbeat is too old.
+func TestStaleHeartbeat(t *testing.T) {
+	c := NewChecker(fakePinger{}, time.Second, time.Minute)
+	if err := c.Live(); err != nil {
+		t.Errorf("unexpected liveness error: %s", err)
+	}
+
+	c.lastHeartbeat = time.Now().Add(-2 * time.Minute).UnixNano()
+	if err := c.Live(); err == nil {
+		t.Error("the service should not be live when the heartbeat is stale")
+	}
+
+	c.Heartbeat()
+	if err := c.Live(); err != nil {
+		t.Errorf("unexpected liveness error after heartbeat: %s", err)
+	}
+}
+
+// TestHandlers verifies the status codes returned by the HTTP handlers.
+func TestHandlers(t *testing.T) {
+	c := NewChecker(fakePinger{}, time.Second, time.Minute)
+
+	w := httptest.NewRecorder()
+	c.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d but got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	c.LivenessHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,107 @@
+// Package identity converts the iRODS users who cause events into the subjects recorded in DataONE event logs.
+//
+// Subjects are looked up in a mapping file containing a map from iRODS user name to DataONE subject, such as an ORCID
+// iD or an LDAP distinguished name. Keys in the mapping file may be unqualified user names or user names qualified
+// with a zone using the iRODS convention, name#zone; qualified names take precedence. Anonymous users are mapped to
+// a fixed subject, which is "public" by default. Any other user is mapped to a subject generated from a template in
+// which {name} and {zone} are replaced with the user name and zone.
+package identity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// PublicSubject is the DataONE subject representing anonymous users.
+const PublicSubject = "public"
+
+// SubjectMapper maps iRODS users to DataONE subjects.
+type SubjectMapper struct {
+	subjects         map[string]string
+	anonymousUsers   map[string]bool
+	anonymousSubject string
+	defaultTemplate  string
+}
+
+// SubjectOptions describes how iRODS users are mapped to DataONE subjects.
+type SubjectOptions struct {
+	MappingFile      string
+	AnonymousUsers   []string
+	AnonymousSubject string
+	DefaultTemplate  string
+}
+
+// LoadSubjectMapping loads a map from iRODS user name to DataONE subject from a YAML or JSON file.
+func LoadSubjectMapping(path string) (map[string]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects map[string]string
+	if err := yaml.Unmarshal(contents, &subjects); err != nil {
+		return nil, fmt.Errorf("unable to parse the subject mapping file %s: %s", path, err)
+	}
+	return subjects, nil
+}
+
+// NewSubjectMapper creates a new SubjectMapper.
+func NewSubjectMapper(opts *SubjectOptions) (*SubjectMapper, error) {
+	m := &SubjectMapper{
+		subjects:         make(map[string]string),
+		anonymousUsers:   make(map[string]bool),
+		anonymousSubject: opts.AnonymousSubject,
+		defaultTemplate:  opts.DefaultTemplate,
+	}
+	if m.anonymousSubject == "" {
+		m.anonymousSubject = PublicSubject
+	}
+
+	if opts.MappingFile != "" {
+		subjects, err := LoadSubjectMapping(opts.MappingFile)
+		if err != nil {
+			return nil, err
+		}
+		m.subjects = subjects
+	}
+
+	for _, user := range opts.AnonymousUsers {
+		m.anonymousUsers[strings.TrimSpace(user)] = true
+	}
+
+	return m, nil
+}
+
+// Identity returns the identity of an iRODS user, including the corresponding DataONE subject. An empty identity is
+// returned if the user is nil.
+func (m *SubjectMapper) Identity(user *model.User) model.Identity {
+	if user == nil {
+		return model.Identity{}
+	}
+	return model.Identity{UserName: user.Name, UserZone: user.Zone, Subject: m.Subject(user)}
+}
+
+// Subject returns the DataONE subject for an iRODS user. An empty string is returned if the user is nil or if the
+// user isn't explicitly mapped and there's no default template.
+func (m *SubjectMapper) Subject(user *model.User) string {
+	if user == nil {
+		return ""
+	}
+
+	qualifiedName := user.Name + "#" + user.Zone
+	if m.anonymousUsers[user.Name] || m.anonymousUsers[qualifiedName] {
+		return m.anonymousSubject
+	}
+	if subject, ok := m.subjects[qualifiedName]; ok {
+		return subject
+	}
+	if subject, ok := m.subjects[user.Name]; ok {
+		return subject
+	}
+
+	return strings.NewReplacer("{name}", user.Name, "{zone}", user.Zone).Replace(m.defaultTemplate)
+}
@@ -0,0 +1,188 @@
+package identity
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// The contents of the subject mapping file used for testing.
+const testMapping = `
+alice: http://orcid.org/0000-0002-1825-0097
+bob#otherzone: CN=Bob,DC=example,DC=org
+`
+
+// writeMapping writes the subject mapping file used for testing and returns its path.
+func writeMapping(t *testing.T) string {
+	f, err := ioutil.TempFile("", "subjects")
+	if err != nil {
+		t.Fatalf("unable to create the subject mapping file: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(testMapping); err != nil {
+		t.Fatalf("unable to write the subject mapping file: %s", err)
+	}
+	return f.Name()
+}
+
+// TestSubjects verifies that users are mapped to the expected subjects.
+func TestSubjects(t *testing.T) {
+	path := writeMapping(t)
+	defer os.Remove(path)
+
+	m, err := NewSubjectMapper(&SubjectOptions{
+		MappingFile:     path,
+		AnonymousUsers:  []string{"anonymous"},
+		DefaultTemplate: "uid={name},ou=People,dc={zone}",
+	})
+	if err != nil {
+		t.Fatalf("unable to create the subject mapper: %s", err)
+	}
+
+	expected := []struct {
+		user    *model.User
+		subject string
+	}{
+		{&model.User{Name: "alice", Zone: "iplant"}, "http://orcid.org/0000-0002-1825-0097"},
+		{&model.User{Name: "bob", Zone: "otherzone"}, "CN=Bob,DC=example,DC=org"},
+		{&model.User{Name: "bob", Zone: "iplant"}, "uid=bob,ou=People,dc=iplant"},
+		{&model.User{Name: "anonymous", Zone: "iplant"}, PublicSubject},
+		{nil, ""},
+	}
+	for _, tc := range expected {
+		if subject := m.Subject(tc.user); subject != tc.subject {
+			t.Errorf("Subject(%+v): expected %q but got %q", tc.user, tc.subject, subject)
+		}
+	}
+}
+
+// TestNoTemplate verifies that unmapped users have no subject if there's no default template.
+func TestNoTemplate(t *testing.T) {
+	m, err := NewSubjectMapper(&SubjectOptions{})
+	if err != nil {
+		t.Fatalf("unable to create the subject mapper: %s", err)
+	}
+	if subject := m.Subject(&model.User{Name: "bob", Zone: "iplant"}); subject != "" {
+		t.Errorf("expected an empty subject but got %q", subject)
+	}
+}
+
+// TestPseudonymize verifies that identities are stored in the form required by each identity mode.
+func TestPseudonymize(t *testing.T) {
+	id := model.Identity{UserName: "alice", UserZone: "iplant", Subject: "http://orcid.org/0000-0002-1825-0097"}
+	keys := []Key{{ID: "new", Secret: "current"}, {ID: "old", Secret: "retired"}}
+
+	raw, err := NewPseudonymizer(ModeRaw, nil)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	if result := raw.Pseudonymize(id); result != id {
+		t.Errorf("raw mode: expected %+v but got %+v", id, result)
+	}
+
+	none, err := NewPseudonymizer(ModeNone, nil)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	if result := none.Pseudonymize(id); result != (model.Identity{}) {
+		t.Errorf("none mode: expected an empty identity but got %+v", result)
+	}
+
+	hashed, err := NewPseudonymizer(ModeKeyedHash, keys)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	result := hashed.Pseudonymize(id)
+	if result.UserName != keys[0].Hash("alice") || result.UserName == keys[1].Hash("alice") {
+		t.Errorf("keyed-hash mode: unexpected user name %q", result.UserName)
+	}
+	if !strings.HasPrefix(result.Subject, "new:") || len(result.Subject) != len("new:")+64 {
+		t.Errorf("keyed-hash mode: unexpected subject %q", result.Subject)
+	}
+	if result.UserZone != id.UserZone {
+		t.Errorf("keyed-hash mode: expected zone %q but got %q", id.UserZone, result.UserZone)
+	}
+	if result := hashed.Pseudonymize(model.Identity{}); result != (model.Identity{}) {
+		t.Errorf("keyed-hash mode: expected an empty identity but got %+v", result)
+	}
+}
+
+// TestInvalidPseudonymizer verifies that invalid identity modes and keys are rejected.
+func TestInvalidPseudonymizer(t *testing.T) {
+	if _, err := NewPseudonymizer("plaintext", nil); err == nil {
+		t.Error("expected an error for an unsupported identity mode")
+	}
+	if _, err := NewPseudonymizer(ModeKeyedHash, nil); err == nil {
+		t.Error("expected an error for keyed-hash mode without keys")
+	}
+	if _, err := NewPseudonymizer(ModeKeyedHash, []Key{{ID: "a:b", Secret: "secret"}}); err == nil {
+		t.Error("expected an error for a key ID containing a colon")
+	}
+}
+
+// TestStoredForms verifies that every stored form of a value is returned, including hashes under retired keys.
+func TestStoredForms(t *testing.T) {
+	keys := []Key{{ID: "new", Secret: "current"}, {ID: "old", Secret: "retired"}}
+	p, err := NewPseudonymizer(ModeRaw, keys)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+
+	forms := p.StoredForms("alice")
+	expected := []string{"alice", keys[0].Hash("alice"), keys[1].Hash("alice")}
+	if len(forms) != len(expected) {
+		t.Fatalf("expected %d stored forms but got %d", len(expected), len(forms))
+	}
+	for i := range expected {
+		if forms[i] != expected[i] {
+			t.Errorf("stored form %d: expected %q but got %q", i, expected[i], forms[i])
+		}
+	}
+}
+
+// TestReference verifies that references use the current key and that no reference is available without keys.
+func TestReference(t *testing.T) {
+	keys := []Key{{ID: "new", Secret: "current"}, {ID: "old", Secret: "retired"}}
+	p, err := NewPseudonymizer(ModeRaw, keys)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	if ref := p.Reference("alice"); ref != keys[0].Hash("alice") {
+		t.Errorf("unexpected reference: %q", ref)
+	}
+
+	unkeyed, err := NewPseudonymizer(ModeRaw, nil)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	if ref := unkeyed.Reference("alice"); ref != "" {
+		t.Errorf("expected an empty reference but got %q", ref)
+	}
+}
+
+// TestArchiveForm verifies that archived messages are tagged with keyed hashes when there are keys, even in none mode.
+func TestArchiveForm(t *testing.T) {
+	id := model.Identity{UserName: "alice", UserZone: "iplant", Subject: "http://orcid.org/0000-0002-1825-0097"}
+	keys := []Key{{ID: "new", Secret: "current"}}
+
+	none, err := NewPseudonymizer(ModeNone, keys)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	result := none.ArchiveForm(id)
+	if result.UserName != keys[0].Hash("alice") || result.UserZone != "iplant" ||
+		result.Subject != keys[0].Hash(id.Subject) {
+		t.Errorf("unexpected archived identity: %+v", result)
+	}
+
+	unkeyed, err := NewPseudonymizer(ModeNone, nil)
+	if err != nil {
+		t.Fatalf("unable to create the pseudonymizer: %s", err)
+	}
+	if result := unkeyed.ArchiveForm(id); result != id {
+		t.Errorf("expected %+v but got %+v", id, result)
+	}
+}
@@ -0,0 +1,116 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// Identity modes, which determine how user identifiers are stored in the event log.
+const (
+	ModeRaw       = "raw"
+	ModeKeyedHash = "keyed-hash"
+	ModeNone      = "none"
+)
+
+// Modes lists every identity mode.
+var Modes = []string{ModeRaw, ModeKeyedHash, ModeNone}
+
+// Key is a secret used to compute keyed hashes of user identifiers. The key ID is stored with each hash so that it's
+// possible to tell which key was used after the keys are rotated.
+type Key struct {
+	ID     string `mapstructure:"id"`
+	Secret string `mapstructure:"secret"`
+}
+
+// Hash computes the keyed hash of a value. The result is the key ID followed by a colon and the hex-encoded
+// HMAC-SHA256 of the value.
+func (k Key) Hash(value string) string {
+	mac := hmac.New(sha256.New, []byte(k.Secret))
+	mac.Write([]byte(value))
+	return k.ID + ":" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Pseudonymizer converts user identities to the form in which they're stored in the event log.
+type Pseudonymizer struct {
+	mode string
+	keys []Key
+}
+
+// NewPseudonymizer creates a new Pseudonymizer. In keyed-hash mode, the first key is used to compute new hashes and
+// the remaining keys are retained so that hashes computed before the keys were rotated can still be recognized.
+func NewPseudonymizer(mode string, keys []Key) (*Pseudonymizer, error) {
+	switch mode {
+	case ModeRaw, ModeNone:
+	case ModeKeyedHash:
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("at least one key is required in %s mode", ModeKeyedHash)
+		}
+		for _, key := range keys {
+			if key.ID == "" || strings.Contains(key.ID, ":") || key.Secret == "" {
+				return nil, fmt.Errorf("invalid key %q: keys require an ID without colons and a secret", key.ID)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported identity mode %q; expected one of: %s", mode, strings.Join(Modes, ", "))
+	}
+	return &Pseudonymizer{mode: mode, keys: keys}, nil
+}
+
+// hash computes the keyed hash of a value using the current key. Empty values remain empty.
+func (p *Pseudonymizer) hash(value string) string {
+	if value == "" {
+		return value
+	}
+	return p.keys[0].Hash(value)
+}
+
+// StoredForms returns every form in which a value may have been stored: the raw value followed by its keyed hash under
+// each key, including keys that have been retired. This is used to find the stored identifiers for a user regardless
+// of the identity mode in effect when each event was recorded.
+func (p *Pseudonymizer) StoredForms(value string) []string {
+	forms := []string{value}
+	for _, key := range p.keys {
+		forms = append(forms, key.Hash(value))
+	}
+	return forms
+}
+
+// Reference returns the keyed hash of a value under the current key, regardless of the identity mode. It's used to
+// refer to a user in audit records without storing an identifier that could be recovered by hashing a list of user
+// names. An empty string is returned if there are no keys.
+func (p *Pseudonymizer) Reference(value string) string {
+	if len(p.keys) == 0 || value == "" {
+		return ""
+	}
+	return p.keys[0].Hash(value)
+}
+
+// ArchiveForm converts an identity to the form in which it's stored alongside an archived message so that the message
+// can be found when the user's events are erased. The user name and subject are replaced with keyed hashes under the
+// current key if there are any keys. Unlike Pseudonymize, the identity is never dropped, because the archived message
+// body contains it anyway.
+func (p *Pseudonymizer) ArchiveForm(id model.Identity) model.Identity {
+	if len(p.keys) == 0 {
+		return id
+	}
+	return model.Identity{UserName: p.hash(id.UserName), UserZone: id.UserZone, Subject: p.hash(id.Subject)}
+}
+
+// Pseudonymize converts an identity to the form in which it should be stored. In keyed-hash mode, the user name and
+// subject are replaced with keyed hashes; the zone is retained so that events can still be aggregated by zone. In
+// none mode, the identity is dropped entirely.
+func (p *Pseudonymizer) Pseudonymize(id model.Identity) model.Identity {
+	switch p.mode {
+	case ModeKeyedHash:
+		return model.Identity{UserName: p.hash(id.UserName), UserZone: id.UserZone, Subject: p.hash(id.Subject)}
+	case ModeNone:
+		return model.Identity{}
+	default:
+		return id
+	}
+}
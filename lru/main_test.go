@@ -0,0 +1,42 @@
+package lru
+
+import (
+	"testing"
+)
+
+// TestEviction verifies that the least recently used entry is evicted when the cache is full.
+func TestEviction(t *testing.T) {
+	c := New(2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries but got %d", c.Len())
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("the least recently used entry was not evicted")
+	}
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Errorf("expected 1 for a but got %v", value)
+	}
+	if value, ok := c.Get("c"); !ok || value != 3 {
+		t.Errorf("expected 3 for c but got %v", value)
+	}
+}
+
+// TestReplaceAndRemove verifies that values can be replaced and removed.
+func TestReplaceAndRemove(t *testing.T) {
+	c := New(0)
+	c.Add("a", 1)
+	c.Add("a", 2)
+	if value, _ := c.Get("a"); value != 2 {
+		t.Errorf("expected 2 for a but got %v", value)
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Error("the entry was not removed")
+	}
+}
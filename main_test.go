@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyverse-de/configurate"
+	"github.com/cyverse-de/dataone-indexer/config"
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/dedup"
+	"github.com/cyverse-de/dataone-indexer/identity"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/cyverse-de/dataone-indexer/repository"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// testConfig contains the settings used by the tests in addition to the default configuration.
+const testConfig = `
+dataone:
+  node-id: urn:node:test
+identity:
+  keys:
+    - id: key1
+      secret: secret
+`
+
+// testDelivery returns an AMQP delivery containing a read event for a file in the default repository root.
+func testDelivery() amqp.Delivery {
+	return amqp.Delivery{
+		RoutingKey:  "data-object.open",
+		ContentType: "application/json",
+		Body: []byte(`{"author": {"name": "ipcdev", "zone": "iplant"}, ` +
+			`"entity": "F3579BF9-284B-4B3C-841B-F6E87D3F78EA", ` +
+			`"path": "/iplant/home/shared/commons_repo/curated/foo.txt"}`),
+	}
+}
+
+// newTestService returns a service that uses a stub database connection and archives every message.
+func newTestService(t *testing.T, db *sql.DB) *DataoneIndexer {
+	cfg, err := configurate.InitDefaultsR(bytes.NewBufferString(testConfig), config.Default)
+	if err != nil {
+		t.Fatalf("unable to load the configuration: %s", err)
+	}
+
+	svc := &DataoneIndexer{
+		cfg: cfg,
+		db:  db,
+		validator: model.NewValidator(
+			cfg.GetDuration("validation.max-event-age"), cfg.GetDuration("validation.max-future"),
+		),
+		maxClockSkew: cfg.GetDuration("timestamps.max-clock-skew"),
+		dedup:        getDeduplicator(cfg, nil),
+		archive:      database.NewArchive(db),
+	}
+	if svc.pids, err = getPIDResolver(cfg, db); err != nil {
+		t.Fatalf("unable to create the persistent identifier resolver: %s", err)
+	}
+	if svc.state, err = svc.newIndexerState(cfg); err != nil {
+		t.Fatalf("unable to initialize the service: %s", err)
+	}
+	return svc
+}
+
+// expectReadEvent describes the database actions used to record a read event derived from an archived message.
+func expectReadEvent(mock sqlmock.Sqlmock, archiveID int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO event_chain_heads").
+		WithArgs("urn:node:test").
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq", "last_hash"}).AddRow(int64(0), ""))
+	mock.ExpectExec("INSERT INTO event_log").
+		WithArgs(
+			"F3579BF9-284B-4B3C-841B-F6E87D3F78EA", "/iplant/home/shared/commons_repo/curated/foo.txt",
+			database.ETRead, sqlmock.AnyArg(), "urn:node:test", "ipcdev", "iplant", nil, false,
+			model.TimestampSourceReceived, nil, model.RoleData, int64(1), nil, sqlmock.AnyArg(), archiveID,
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE event_chain_heads").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+// fakeBroker records the routing keys bound to the queue. Binding the routing key in failKey fails, and the channel
+// that attempted the binding is closed, as it would be by a real broker.
+type fakeBroker struct {
+	bindings map[string]bool
+	failKey  string
+}
+
+// fakeChannel is a channel opened on a fake broker.
+type fakeChannel struct {
+	broker *fakeBroker
+	closed bool
+}
+
+// open opens a new channel on a fake broker.
+func (b *fakeBroker) open() (bindingChannel, error) {
+	return &fakeChannel{broker: b}, nil
+}
+
+// QueueBind binds a routing key to the queue.
+func (c *fakeChannel) QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error {
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	if key == c.broker.failKey {
+		c.closed = true
+		return errors.New("access refused")
+	}
+	c.broker.bindings[key] = true
+	return nil
+}
+
+// QueueUnbind unbinds a routing key from the queue.
+func (c *fakeChannel) QueueUnbind(name, key, exchange string, args amqp.Table) error {
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	delete(c.broker.bindings, key)
+	return nil
+}
+
+// Close closes the channel.
+func (c *fakeChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+// TestUpdateBindings verifies that the routing key bindings are changed when a reloaded configuration is applied.
+func TestUpdateBindings(t *testing.T) {
+	broker := &fakeBroker{bindings: map[string]bool{"data-object.open": true, "data-object.add": true}}
+	oldKeys := map[string]string{"read": "data-object.open", "create": "data-object.add"}
+	newKeys := map[string]string{"read": "data-object.open", "update": "data-object.mod"}
+
+	if err := updateBindings(broker.open, "de", oldKeys, newKeys); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]bool{"data-object.open": true, "data-object.mod": true}
+	if !reflect.DeepEqual(broker.bindings, expected) {
+		t.Errorf("unexpected bindings: %v", broker.bindings)
+	}
+}
+
+// TestUpdateBindingsRollback verifies that the original bindings are restored if one of the routing keys can't be
+// bound.
+func TestUpdateBindingsRollback(t *testing.T) {
+	broker := &fakeBroker{
+		bindings: map[string]bool{"data-object.open": true, "data-object.add": true},
+		failKey:  "data-object.mv",
+	}
+	oldKeys := map[string]string{"read": "data-object.open", "create": "data-object.add"}
+	newKeys := map[string]string{"update": "data-object.mod", "move": "data-object.mv", "delete": "data-object.rm"}
+
+	if err := updateBindings(broker.open, "de", oldKeys, newKeys); err == nil {
+		t.Fatal("an error was expected but none was returned")
+	}
+
+	expected := map[string]bool{"data-object.open": true, "data-object.add": true}
+	if !reflect.DeepEqual(broker.bindings, expected) {
+		t.Errorf("the original bindings were not restored: %v", broker.bindings)
+	}
+}
+
+// TestErasureCriteria verifies that erasure audit records refer to users by keyed hash.
+func TestErasureCriteria(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("identity.mode", identity.ModeRaw)
+	cfg.Set("identity.keys", []map[string]interface{}{{"id": "key1", "secret": "secret"}})
+
+	req, err := buildErasureRequest(cfg, "alice#iplant", "")
+	if err != nil {
+		t.Fatalf("unable to build the erasure request: %s", err)
+	}
+	key := identity.Key{ID: "key1", Secret: "secret"}
+	if req.Criteria != "user "+key.Hash("alice#iplant") {
+		t.Errorf("unexpected criteria: %s", req.Criteria)
+	}
+	if strings.Contains(req.Criteria, "alice") {
+		t.Errorf("the criteria contain the user name: %s", req.Criteria)
+	}
+	if len(req.RequestID) != 32 {
+		t.Errorf("unexpected request ID: %q", req.RequestID)
+	}
+
+	// Only the type of identifier is recorded without identity keys.
+	cfg.Set("identity.keys", []map[string]interface{}{})
+	if req, err = buildErasureRequest(cfg, "", "http://orcid.org/0000-0002-1825-0097"); err != nil {
+		t.Fatalf("unable to build the erasure request: %s", err)
+	}
+	if req.Criteria != "subject" {
+		t.Errorf("unexpected criteria: %s", req.Criteria)
+	}
+}
+
+// TestMatchMove verifies that moves into and out of the repository are recorded as creates and deletes.
+func TestMatchMove(t *testing.T) {
+	matcher, err := repository.NewMatcher(nil)
+	if err != nil {
+		t.Fatalf("unable to create the matcher: %s", err)
+	}
+	if err := matcher.AddRoot("/iplant/home/shared/commons_repo", &repository.Owner{NodeID: "node"}); err != nil {
+		t.Fatalf("unable to add the repository root: %s", err)
+	}
+	state := &indexerState{matcher: matcher}
+
+	inside := "/iplant/home/shared/commons_repo/foo"
+	outside := "/iplant/home/ipcdev/foo"
+	tests := []struct {
+		source, path string
+		handler      string
+		recordedPath string
+		ok           bool
+	}{
+		{inside + "/a", inside + "/b", database.HandlerMove, inside + "/b", true},
+		{outside, inside, database.HandlerCreate, inside, true},
+		{inside, outside, database.HandlerDelete, inside, true},
+		{outside, outside + "/b", database.HandlerMove, outside + "/b", false},
+	}
+	for _, test := range tests {
+		msg := &model.Message{Path: test.path, SourcePath: test.source}
+		rule, handler, ok := state.match(msg, database.HandlerMove)
+		if ok != test.ok || handler != test.handler || msg.Path != test.recordedPath {
+			t.Errorf("%s -> %s: unexpected result: %s %s %t", test.source, test.path, handler, msg.Path, ok)
+		}
+		if ok && (rule == nil || rule.Owner.NodeID != "node") {
+			t.Errorf("%s -> %s: unexpected rule: %v", test.source, test.path, rule)
+		}
+	}
+
+	// Other handlers only consider the destination path.
+	msg := &model.Message{Path: outside, SourcePath: inside}
+	if _, _, ok := state.match(msg, database.HandlerUpdate); ok {
+		t.Error("an update outside of the repository was matched")
+	}
+}
+
+// TestDedupPackageEvent verifies that only one package-level event is requested when a user reads several files in a
+// dataset within the deduplication window.
+func TestDedupPackageEvent(t *testing.T) {
+	svc := &DataoneIndexer{dedup: dedup.NewDeduplicator(time.Minute, 10, nil)}
+	alice := &model.User{Name: "alice", Zone: "iplant"}
+	bob := &model.User{Name: "bob", Zone: "iplant"}
+
+	read := func(path string, user *model.User) bool {
+		msg := &model.Message{
+			Path:         path,
+			Author:       user,
+			Timestamp:    model.CurrentTimestamp(),
+			DatasetID:    "doi:10.7946/P2X",
+			PackageEvent: true,
+		}
+		svc.dedupPackageEvent(context.Background(), msg)
+		return msg.PackageEvent
+	}
+
+	if !read("/dataset/a", alice) {
+		t.Error("the first read of the dataset did not request a package event")
+	}
+	if read("/dataset/b", alice) {
+		t.Error("a repeated read of the dataset by the same user requested a package event")
+	}
+	if !read("/dataset/b", bob) {
+		t.Error("a read of the dataset by a different user did not request a package event")
+	}
+}
+
+// TestDecodeReplayedAudit verifies that replayed audit messages are decoded according to the schema version recorded
+// when they were archived even if the audit routing key has changed since then.
+func TestDecodeReplayedAudit(t *testing.T) {
+	state := &indexerState{auditRoutingKey: "audit.new", auditDecoder: model.NewAuditDecoder(nil)}
+	delivery := amqp.Delivery{
+		RoutingKey:  "audit.old",
+		ContentType: "application/json",
+		Headers:     amqp.Table{model.HeaderSchemaVersion: model.SchemaIRODSAudit},
+		Body: []byte(`{"rule_name": "audit_pep_api_data_obj_put_post", "user_user_name": "ipcdev", ` +
+			`"user_rods_zone": "iplant", "obj_path": "/iplant/home/shared/commons_repo/curated/foo.txt"}`),
+	}
+
+	msg, err := decodeDelivery(state, delivery, true)
+	if err != nil {
+		t.Fatalf("unable to decode the replayed message: %s", err)
+	}
+	if msg == nil || msg.SchemaVersion != model.SchemaIRODSAudit || msg.Handler != "create" {
+		t.Errorf("the replayed message was not decoded by the audit decoder: %+v", msg)
+	}
+
+	if _, err := decodeDelivery(state, delivery, false); err == nil {
+		t.Error("a live message with an old audit routing key was decoded by the audit decoder")
+	}
+}
+
+// TestArchiveValidMessages verifies that live messages are archived once they pass validation and that the events
+// derived from them refer to the archived message.
+func TestArchiveValidMessages(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+	svc := newTestService(t, db)
+
+	// Describe the expected database actions.
+	mock.ExpectQuery("INSERT INTO message_archive").
+		WithArgs(
+			sqlmock.AnyArg(), "data-object.open", "application/json", model.SchemaVersion1, nil, sqlmock.AnyArg(),
+			identity.Key{ID: "key1", Secret: "secret"}.Hash("ipcdev"), "iplant", nil,
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(42)))
+	expectReadEvent(mock, 42)
+
+	// Process the message.
+	if err := svc.processDelivery(testDelivery(), time.Now(), 0); err != nil {
+		t.Fatalf("error encountered while processing the message: %s", err)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+
+	// Invalid messages aren't archived.
+	mock.ExpectQuery("INSERT INTO message_archive").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(43)))
+	delivery := testDelivery()
+	delivery.Body = []byte(`{"entity": "F3579BF9-284B-4B3C-841B-F6E87D3F78EA"}`)
+	if _, ok := svc.processDelivery(delivery, time.Now(), 0).(model.ValidationErrors); !ok {
+		t.Error("expected the invalid message to be rejected")
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Error("the invalid message was archived")
+	}
+}
+
+// TestReplayRecorder verifies that replayed messages are recorded only if events haven't already been recorded for
+// them.
+func TestReplayRecorder(t *testing.T) {
+
+	// Create the stub database connection.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening stub database connection: %s", err)
+	}
+	svc := newTestService(t, db)
+	svc.dedup = dedup.NewDeduplicator(0, 1, nil)
+	recorder := &replayRecorder{Recorder: svc.state.recorder, archive: svc.archive}
+	svc.state.recorder = recorder
+
+	// Describe the expected database actions.
+	expectRecorded := func(id int64, recorded bool) {
+		mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM event_log").
+			WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(recorded))
+	}
+	expectRecorded(42, false)
+	expectReadEvent(mock, 42)
+	expectRecorded(42, true)
+
+	// Replay the messages. Deduplication is disabled so that the same message can be replayed more than once.
+	for _, id := range []int64{42, 42} {
+		if err := svc.processDelivery(testDelivery(), time.Now(), id); err != nil {
+			t.Fatalf("error encountered while replaying message %d: %s", id, err)
+		}
+	}
+	if recorder.recorded != 1 || recorder.skipped != 1 {
+		t.Errorf("unexpected counts: %d recorded, %d skipped", recorder.recorded, recorder.skipped)
+	}
+
+	// Verify that the expectations were met.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
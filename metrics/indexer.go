@@ -0,0 +1,131 @@
+package metrics
+
+// Histogram bucket upper bounds, in seconds.
+var (
+	latencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	lagBuckets     = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600, 21600, 86400}
+)
+
+// Metrics reported by the DataONE indexer.
+var (
+	MessagesReceived = newCounterVec(
+		"dataone_indexer_messages_received_total",
+		"The number of AMQP messages received, partitioned by routing key.",
+		"routing_key",
+	)
+
+	MessagesDecoded = newCounterVec(
+		"dataone_indexer_messages_decoded_total",
+		"The number of messages decoded successfully, partitioned by schema version.",
+		"schema_version",
+	)
+
+	MessagesRejected = newCounterVec(
+		"dataone_indexer_messages_rejected_total",
+		"The number of messages rejected because they failed validation, partitioned by reason.",
+		"reason",
+	)
+
+	AuditMessagesIgnored = newCounter(
+		"dataone_indexer_audit_messages_ignored_total",
+		"The number of iRODS audit plugin messages ignored because they aren't mapped to an enabled handler.",
+	)
+
+	MessagesFiltered = newCounterVec(
+		"dataone_indexer_messages_filtered_total",
+		"The number of messages ignored because the path is not in a repository root, partitioned by routing key.",
+		"routing_key",
+	)
+
+	EventsExcluded = newCounterVec(
+		"dataone_indexer_events_excluded_total",
+		"The number of events ignored because of the user who caused them, partitioned by reason.",
+		"reason",
+	)
+
+	RobotEventsFlagged = newCounter(
+		"dataone_indexer_robot_events_flagged_total",
+		"The number of events recorded with a flag indicating that they were caused by robots.",
+	)
+
+	DuplicateReads = newCounter(
+		"dataone_indexer_duplicate_reads_total",
+		"The number of read events ignored because they repeat a recent read of the same entity by the same user.",
+	)
+
+	DuplicatePackageReads = newCounter(
+		"dataone_indexer_duplicate_package_reads_total",
+		"The number of package-level read events ignored because the same user recently read the dataset.",
+	)
+
+	EventsRecorded = newCounterVec(
+		"dataone_indexer_events_recorded_total",
+		"The number of events recorded in the event database, partitioned by event type.",
+		"event_type",
+	)
+
+	IdentitiesScrubbed = newCounter(
+		"dataone_indexer_identities_scrubbed_total",
+		"The number of events whose user identifiers were removed after the retention period expired.",
+	)
+
+	DecodeFailures = newCounter(
+		"dataone_indexer_decode_failures_total",
+		"The number of AMQP messages that could not be decoded.",
+	)
+
+	DatabaseErrors = newCounter(
+		"dataone_indexer_database_errors_total",
+		"The number of errors encountered while recording events in the event database.",
+	)
+
+	Acknowledgements = newCounterVec(
+		"dataone_indexer_acknowledgements_total",
+		"The number of AMQP message acknowledgements sent, partitioned by type (ack or nack).",
+		"type",
+	)
+
+	ReadsByRole = newCounterVec(
+		"dataone_indexer_reads_by_role_total",
+		"The number of read events recorded, partitioned by the role of the repository root.",
+		"role",
+	)
+
+	DatasetReads = newCounter(
+		"dataone_indexer_dataset_reads_total",
+		"The number of read events attributed to a dataset.",
+	)
+
+	PackageEventsRecorded = newCounter(
+		"dataone_indexer_package_events_recorded_total",
+		"The number of package-level read events recorded for datasets.",
+	)
+
+	MessagesArchived = newCounter(
+		"dataone_indexer_messages_archived_total",
+		"The number of decoded messages added to the raw message archive.",
+	)
+
+	PIDFallbacks = newCounter(
+		"dataone_indexer_pid_fallbacks_total",
+		"The number of events recorded with the iRODS UUID because no persistent identifier could be resolved.",
+	)
+
+	ClockSkewExceeded = newCounterVec(
+		"dataone_indexer_clock_skew_exceeded_total",
+		"The number of events whose timestamps exceed the maximum clock skew, partitioned by timestamp source.",
+		"timestamp_source",
+	)
+
+	ProcessingLatency = newHistogram(
+		"dataone_indexer_processing_duration_seconds",
+		"The amount of time spent processing a single AMQP message.",
+		latencyBuckets,
+	)
+
+	EventLag = newHistogram(
+		"dataone_indexer_event_lag_seconds",
+		"The amount of time between the event timestamp in a message and the time the event was recorded.",
+		lagBuckets,
+	)
+)
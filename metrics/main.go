@@ -0,0 +1,54 @@
+// Package metrics provides the counters and histograms that the DataONE indexer exposes for Prometheus.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultRegistry is the registry containing the metrics reported by the DataONE indexer. The standard Go runtime and
+// process metrics are included along with the metrics defined in this package.
+var DefaultRegistry = newRegistry()
+
+// newRegistry creates a registry containing the standard Go runtime and process collectors.
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+	r.MustRegister(prometheus.NewGoCollector())
+	r.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return r
+}
+
+// newCounter creates a counter without any labels and adds it to the default registry.
+func newCounter(name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	DefaultRegistry.MustRegister(c)
+	return c
+}
+
+// newCounterVec creates a set of counters partitioned by label values and adds it to the default registry.
+func newCounterVec(name, help string, labelNames ...string) *prometheus.CounterVec {
+	cv := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames)
+	DefaultRegistry.MustRegister(cv)
+	return cv
+}
+
+// newHistogram creates a histogram without any labels and adds it to the default registry. The bucket upper bounds
+// must be sorted in increasing order.
+func newHistogram(name, help string, buckets []float64) prometheus.Histogram {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets})
+	DefaultRegistry.MustRegister(h)
+	return h
+}
+
+// Handler returns an HTTP handler that writes the metrics in the default registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(DefaultRegistry, promhttp.HandlerOpts{})
+}
+
+// ObserveDuration adds the number of seconds since a start time to a histogram.
+func ObserveDuration(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
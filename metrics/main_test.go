@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scrape returns the lines written by the metrics handler.
+func scrape(t *testing.T) []string {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status code: %d", rec.Code)
+	}
+	return strings.Split(rec.Body.String(), "\n")
+}
+
+// expectLines verifies that each of the expected lines appears in the output of the metrics handler.
+func expectLines(t *testing.T, expected []string) {
+	lines := scrape(t)
+
+	for _, e := range expected {
+		found := false
+		for _, line := range lines {
+			if line == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("expected line `%s` not found in output:\n%s", e, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// TestHandler verifies that the indexer metrics are written by the metrics handler.
+func TestHandler(t *testing.T) {
+	MessagesReceived.WithLabelValues("test.key").Inc()
+	MessagesReceived.WithLabelValues("test.key").Inc()
+	DecodeFailures.Inc()
+
+	expectLines(t, []string{
+		"# HELP dataone_indexer_messages_received_total The number of AMQP messages received, partitioned by routing key.",
+		"# TYPE dataone_indexer_messages_received_total counter",
+		`dataone_indexer_messages_received_total{routing_key="test.key"} 2`,
+		"dataone_indexer_decode_failures_total 1",
+		"# TYPE dataone_indexer_processing_duration_seconds histogram",
+	})
+}
+
+// TestObserveDuration verifies that durations are added to histograms in seconds.
+func TestObserveDuration(t *testing.T) {
+	ObserveDuration(EventLag, time.Now().Add(-2*time.Second))
+
+	expectLines(t, []string{
+		`dataone_indexer_event_lag_seconds_bucket{le="1"} 0`,
+		`dataone_indexer_event_lag_seconds_bucket{le="5"} 1`,
+		"dataone_indexer_event_lag_seconds_count 1",
+	})
+}
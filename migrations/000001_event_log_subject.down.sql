@@ -0,0 +1,3 @@
+ALTER TABLE event_log DROP COLUMN IF EXISTS subject;
+ALTER TABLE event_log DROP COLUMN IF EXISTS user_zone;
+ALTER TABLE event_log DROP COLUMN IF EXISTS user_name;
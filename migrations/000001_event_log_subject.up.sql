@@ -0,0 +1,4 @@
+-- Record the iRODS user who caused each event and the corresponding DataONE subject.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS user_name TEXT;
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS user_zone TEXT;
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS subject TEXT;
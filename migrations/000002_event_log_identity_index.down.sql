@@ -0,0 +1 @@
+DROP INDEX IF EXISTS event_log_identity_date_logged_idx;
@@ -0,0 +1,3 @@
+-- Support the periodic removal of user identifiers from events that are older than the retention period.
+CREATE INDEX IF NOT EXISTS event_log_identity_date_logged_idx ON event_log (date_logged)
+WHERE user_name IS NOT NULL OR user_zone IS NOT NULL OR subject IS NOT NULL;
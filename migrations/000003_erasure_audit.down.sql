@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS event_log_subject_idx;
+DROP INDEX IF EXISTS event_log_user_name_idx;
+DROP TABLE IF EXISTS erasure_audit;
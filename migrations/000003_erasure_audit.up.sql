@@ -0,0 +1,14 @@
+-- Record the changes made when a user's events are erased. The erased user is described by the criteria column.
+CREATE TABLE IF NOT EXISTS erasure_audit (
+    id BIGSERIAL PRIMARY KEY,
+    erased_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    action TEXT NOT NULL,
+    criteria TEXT NOT NULL,
+    table_name TEXT NOT NULL,
+    rows_affected BIGINT NOT NULL,
+    requested_by TEXT,
+    reference TEXT
+);
+
+CREATE INDEX IF NOT EXISTS event_log_user_name_idx ON event_log (user_name) WHERE user_name IS NOT NULL;
+CREATE INDEX IF NOT EXISTS event_log_subject_idx ON event_log (subject) WHERE subject IS NOT NULL;
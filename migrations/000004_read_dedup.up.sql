@@ -0,0 +1,7 @@
+-- Share read deduplication claims among indexer replicas. Keys are keyed hashes of the entity and user.
+CREATE TABLE IF NOT EXISTS read_dedup (
+    dedup_key TEXT PRIMARY KEY,
+    last_claimed TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS read_dedup_last_claimed_idx ON read_dedup (last_claimed);
@@ -0,0 +1,2 @@
+-- Flag events caused by robots so that reports can exclude them.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS robot BOOLEAN NOT NULL DEFAULT FALSE;
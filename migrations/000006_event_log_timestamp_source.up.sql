@@ -0,0 +1,2 @@
+-- Record where the timestamp of each event was obtained: the message body, the AMQP delivery or the time received.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS timestamp_source TEXT;
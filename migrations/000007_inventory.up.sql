@@ -0,0 +1,15 @@
+-- Track the objects that are currently in the repository, keyed by iRODS UUID. The persistent identifier is recorded
+-- once it's known. Deleted objects are retained with a deletion date.
+CREATE TABLE IF NOT EXISTS inventory (
+    irods_uuid TEXT PRIMARY KEY,
+    permanent_id TEXT,
+    irods_path TEXT NOT NULL,
+    node_identifier TEXT NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE,
+    date_modified TIMESTAMP WITH TIME ZONE NOT NULL,
+    date_deleted TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS inventory_permanent_id_idx ON inventory (permanent_id) WHERE permanent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS inventory_irods_path_idx ON inventory (irods_path) WHERE date_deleted IS NULL;
+CREATE INDEX IF NOT EXISTS inventory_node_identifier_idx ON inventory (node_identifier) WHERE date_deleted IS NULL;
@@ -0,0 +1,6 @@
+-- Map iRODS objects to DataONE persistent identifiers. Set pids.table to pid_mapping to use this table.
+CREATE TABLE IF NOT EXISTS pid_mapping (
+    irods_uuid TEXT UNIQUE,
+    irods_path TEXT UNIQUE,
+    pid TEXT NOT NULL
+);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS event_log_dataset_id_idx;
+
+ALTER TABLE event_log DROP COLUMN IF EXISTS dataset_id;
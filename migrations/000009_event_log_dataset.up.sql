@@ -0,0 +1,4 @@
+-- Attribute events to the dataset containing the object, identified by the dataset's persistent identifier or path.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS dataset_id TEXT;
+
+CREATE INDEX IF NOT EXISTS event_log_dataset_id_idx ON event_log (dataset_id) WHERE dataset_id IS NOT NULL;
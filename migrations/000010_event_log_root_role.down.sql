@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS usage_events;
+
+ALTER TABLE event_log DROP COLUMN IF EXISTS root_role;
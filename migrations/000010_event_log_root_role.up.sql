@@ -0,0 +1,10 @@
+-- Record the role of the repository root containing each object: data, metadata or resource-map.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS root_role TEXT;
+
+-- Classify reads for COUNTER usage reporting. Reads of data objects are requests (downloads), and reads of metadata
+-- documents and resource maps are investigations (views). Events recorded before roles were tracked are data reads.
+CREATE OR REPLACE VIEW usage_events AS
+SELECT e.*,
+    CASE WHEN COALESCE(e.root_role, 'data') = 'data' THEN 'request' ELSE 'investigation' END AS counter_metric
+FROM event_log e
+WHERE e.event = 'READ';
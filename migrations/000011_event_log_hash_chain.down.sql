@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS event_chain_heads;
+
+DROP INDEX IF EXISTS event_log_chain_idx;
+
+ALTER TABLE event_log DROP COLUMN IF EXISTS row_hash;
+ALTER TABLE event_log DROP COLUMN IF EXISTS previous_hash;
+ALTER TABLE event_log DROP COLUMN IF EXISTS chain_seq;
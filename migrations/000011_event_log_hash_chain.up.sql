@@ -0,0 +1,15 @@
+-- Chain the events recorded for each node together with hashes so that edits to the event log can be detected. Each
+-- event carries its position in the chain, the hash of the previous event and its own hash.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS previous_hash TEXT;
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS row_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_log_chain_idx ON event_log (node_identifier, chain_seq)
+    WHERE chain_seq IS NOT NULL;
+
+-- Track the last event in the chain for each node. Inserts lock the node's row so that events are chained in order.
+CREATE TABLE IF NOT EXISTS event_chain_heads (
+    node_identifier TEXT PRIMARY KEY,
+    last_seq BIGINT NOT NULL,
+    last_hash TEXT NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS event_log_archive_id_idx;
+
+ALTER TABLE event_log DROP COLUMN IF EXISTS archive_id;
+
+DROP TABLE IF EXISTS message_archive;
@@ -0,0 +1,26 @@
+-- Keep every valid message so that messages can be replayed through the current filters by the reindex command. The
+-- user who caused each message is recorded so that archived messages can be erased along with the user's events. The
+-- body is removed when a user's events are anonymized and when the identity retention period expires.
+CREATE TABLE IF NOT EXISTS message_archive (
+    id BIGSERIAL PRIMARY KEY,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    routing_key TEXT NOT NULL,
+    content_type TEXT,
+    schema_version TEXT,
+    delivery_time TIMESTAMP WITH TIME ZONE,
+    body BYTEA,
+    user_name TEXT,
+    user_zone TEXT,
+    subject TEXT
+);
+
+CREATE INDEX IF NOT EXISTS message_archive_received_at_idx ON message_archive (received_at);
+CREATE INDEX IF NOT EXISTS message_archive_user_name_idx ON message_archive (user_name) WHERE user_name IS NOT NULL;
+CREATE INDEX IF NOT EXISTS message_archive_subject_idx ON message_archive (subject) WHERE subject IS NOT NULL;
+
+-- Record the archived message that each event was derived from. A message yields at most one event per path, so the
+-- unique index prevents concurrent replays from recording duplicate events.
+ALTER TABLE event_log ADD COLUMN IF NOT EXISTS archive_id BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS event_log_archive_id_idx ON event_log (archive_id, irods_path)
+    WHERE archive_id IS NOT NULL;
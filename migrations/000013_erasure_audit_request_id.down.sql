@@ -0,0 +1 @@
+ALTER TABLE erasure_audit DROP COLUMN IF EXISTS request_id;
@@ -0,0 +1,2 @@
+-- Link the audit records written for a single erasure request.
+ALTER TABLE erasure_audit ADD COLUMN IF NOT EXISTS request_id TEXT;
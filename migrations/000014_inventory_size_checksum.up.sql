@@ -0,0 +1,3 @@
+-- Record the size and checksum reported by version 2 and iRODS audit messages.
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS size BIGINT;
+ALTER TABLE inventory ADD COLUMN IF NOT EXISTS checksum TEXT;
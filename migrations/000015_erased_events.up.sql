@@ -0,0 +1,11 @@
+-- Record the hash chain positions of events deleted by user erasures so that the gaps they leave in the chain can be
+-- verified and reported as erasures rather than tampering.
+CREATE TABLE IF NOT EXISTS erased_events (
+    node_identifier TEXT NOT NULL,
+    chain_seq BIGINT NOT NULL,
+    previous_hash TEXT,
+    row_hash TEXT NOT NULL,
+    request_id TEXT,
+    erased_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (node_identifier, chain_seq)
+);
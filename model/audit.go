@@ -0,0 +1,136 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaIRODSAudit is the schema version assigned to messages published by the iRODS audit plugin.
+const SchemaIRODSAudit = "irods-audit"
+
+// auditRulePrefix is the prefix that the audit plugin adds to the names of policy enforcement points.
+const auditRulePrefix = "audit_"
+
+// Open flags used to determine whether a data object was opened for reading.
+const (
+	openAccessMode = 0x3
+	openReadOnly   = 0x0
+)
+
+// DefaultAuditPEPs maps the policy enforcement points reported by the iRODS audit plugin to the names of the handlers
+// that record them.
+var DefaultAuditPEPs = map[string]string{
+	"pep_api_data_obj_open_post":   "read",
+	"pep_api_data_obj_get_post":    "read",
+	"pep_api_data_obj_put_post":    "create",
+	"pep_api_data_obj_create_post": "create",
+	"pep_api_data_obj_copy_post":   "create",
+	"pep_api_data_obj_rename_post": "move",
+	"pep_api_data_obj_unlink_post": "delete",
+}
+
+// auditMessage contains the fields of an audit plugin message that are used by the indexer. The audit plugin
+// flattens the arguments of each policy enforcement point into a single JSON object whose values are all strings.
+type auditMessage struct {
+	RuleName   string `json:"rule_name"`
+	TimeStamp  string `json:"time_stamp"`
+	UserName   string `json:"user_user_name"`
+	UserZone   string `json:"user_rods_zone"`
+	ClientAddr string `json:"client_addr"`
+	ObjPath    string `json:"obj_path"`
+	SrcPath    string `json:"src_obj_path"`
+	DstPath    string `json:"dst_obj_path"`
+	OpenFlags  string `json:"open_flags"`
+	DataSize   string `json:"data_size"`
+}
+
+// AuditDecoder converts messages published by the iRODS audit plugin to Messages. Each message is mapped to a handler
+// by the name of its policy enforcement point.
+type AuditDecoder struct {
+	peps map[string]string
+}
+
+// NewAuditDecoder creates a new AuditDecoder. The overrides are merged into DefaultAuditPEPs; mapping a policy
+// enforcement point to an empty handler name causes it to be ignored.
+func NewAuditDecoder(overrides map[string]string) *AuditDecoder {
+	peps := make(map[string]string)
+	for pep, handler := range DefaultAuditPEPs {
+		peps[pep] = handler
+	}
+	for pep, handler := range overrides {
+		peps[pep] = handler
+	}
+	return &AuditDecoder{peps: peps}
+}
+
+// Handlers returns the names of the handlers that at least one policy enforcement point is mapped to. Only these
+// handlers record events from audit messages.
+func (d *AuditDecoder) Handlers() map[string]bool {
+	handlers := make(map[string]bool)
+	for _, handler := range d.peps {
+		if handler != "" {
+			handlers[handler] = true
+		}
+	}
+	return handlers
+}
+
+// parseAuditTime converts an audit plugin timestamp, which is the number of milliseconds since the epoch, to a
+// Timestamp. Nil is returned if the timestamp can't be parsed.
+func parseAuditTime(value string) *Timestamp {
+	ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	return (*Timestamp)(&t)
+}
+
+// Decode converts an audit plugin message to a Message with the Handler field set. The second return value is false
+// if the message should be ignored, either because its policy enforcement point isn't mapped to a handler or because
+// a data object was opened for writing rather than reading. Audit messages don't contain entity IDs, so events can only
+// be recorded once the persistent identifier of the data object has been resolved from its path.
+func (d *AuditDecoder) Decode(body []byte) (*Message, bool, error) {
+	var wire auditMessage
+	if err := json.Unmarshal(body, &wire); err != nil {
+		return nil, false, malformed(err)
+	}
+
+	// Determine which handler should record the event.
+	pep := strings.TrimPrefix(wire.RuleName, auditRulePrefix)
+	handler := d.peps[pep]
+	if handler == "" {
+		return nil, false, nil
+	}
+
+	// Opening a data object for writing isn't a read.
+	if handler == "read" && wire.OpenFlags != "" {
+		flags, err := strconv.ParseInt(wire.OpenFlags, 0, 64)
+		if err == nil && flags&openAccessMode != openReadOnly {
+			return nil, false, nil
+		}
+	}
+
+	msg := &Message{
+		Timestamp:     parseAuditTime(wire.TimeStamp),
+		Path:          wire.ObjPath,
+		IPAddress:     wire.ClientAddr,
+		SchemaVersion: SchemaIRODSAudit,
+		Handler:       handler,
+	}
+	if wire.UserName != "" {
+		msg.Author = &User{Name: wire.UserName, Zone: wire.UserZone}
+	}
+	if wire.DstPath != "" {
+		msg.Path, msg.SourcePath = wire.DstPath, wire.SrcPath
+	}
+	if size, err := strconv.ParseInt(wire.DataSize, 10, 64); err == nil && size >= 0 {
+		msg.Size = &size
+	}
+
+	msg.Path = CanonicalPath(msg.Path)
+	msg.SourcePath = CanonicalPath(msg.SourcePath)
+	return msg, true, nil
+}
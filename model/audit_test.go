@@ -0,0 +1,126 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+var auditOpen = []byte(`
+{
+  "rule_name": "audit_pep_api_data_obj_open_post",
+  "time_stamp": "1507302457000",
+  "user_user_name": "nobody",
+  "user_rods_zone": "nowhere",
+  "client_addr": "192.0.2.1",
+  "obj_path": "/foo//bar",
+  "open_flags": "0"
+}
+`)
+
+var auditOpenForWrite = []byte(`
+{
+  "rule_name": "audit_pep_api_data_obj_open_post",
+  "user_user_name": "nobody",
+  "user_rods_zone": "nowhere",
+  "obj_path": "/foo/bar",
+  "open_flags": "2"
+}
+`)
+
+var auditRename = []byte(`
+{
+  "rule_name": "audit_pep_api_data_obj_rename_post",
+  "user_user_name": "nobody",
+  "user_rods_zone": "nowhere",
+  "src_obj_path": "/foo/baz",
+  "dst_obj_path": "/foo/bar"
+}
+`)
+
+var auditWrite = []byte(`
+{
+  "rule_name": "audit_pep_api_data_obj_write_post",
+  "user_user_name": "nobody",
+  "user_rods_zone": "nowhere",
+  "obj_path": "/foo/bar"
+}
+`)
+
+func TestAuditOpen(t *testing.T) {
+	msg, ok, err := NewAuditDecoder(nil).Decode(auditOpen)
+	if err != nil || !ok {
+		t.Fatalf("unable to decode the audit message: %v", err)
+	}
+
+	msg.Entity = "fakeid"
+	validateCommonFields(t, msg)
+	if msg.Handler != "read" || msg.SchemaVersion != SchemaIRODSAudit {
+		t.Errorf("unexpected handler or schema version: %+v", msg)
+	}
+	if msg.IPAddress != "192.0.2.1" {
+		t.Errorf("unexpected IP address: %s", msg.IPAddress)
+	}
+	expected := time.Date(2017, time.October, 6, 15, 7, 37, 0, time.UTC)
+	if msg.Timestamp == nil || !msg.Timestamp.ToTime().Equal(expected) {
+		t.Errorf("expected timestamp %s but got %v", expected, msg.Timestamp)
+	}
+}
+
+func TestAuditRename(t *testing.T) {
+	msg, ok, err := NewAuditDecoder(nil).Decode(auditRename)
+	if err != nil || !ok {
+		t.Fatalf("unable to decode the audit message: %v", err)
+	}
+	if msg.Handler != "move" || msg.Path != "/foo/bar" || msg.SourcePath != "/foo/baz" {
+		t.Errorf("unexpected move message: %+v", msg)
+	}
+}
+
+func TestIgnoredAuditMessages(t *testing.T) {
+	d := NewAuditDecoder(nil)
+	for _, body := range [][]byte{auditOpenForWrite, auditWrite} {
+		if _, ok, err := d.Decode(body); err != nil || ok {
+			t.Errorf("expected the audit message to be ignored: %s", body)
+		}
+	}
+
+	// Overrides can map additional policy enforcement points to handlers or disable the defaults.
+	d = NewAuditDecoder(map[string]string{"pep_api_data_obj_write_post": "update", "pep_api_data_obj_open_post": ""})
+	if msg, ok, err := d.Decode(auditWrite); err != nil || !ok || msg.Handler != "update" {
+		t.Errorf("expected the write to be recorded as an update: %+v", msg)
+	}
+	if _, ok, _ := d.Decode(auditOpen); ok {
+		t.Error("expected the open to be ignored")
+	}
+}
+
+func TestAuditValidation(t *testing.T) {
+	msg, _, err := NewAuditDecoder(nil).Decode(auditOpen)
+	if err != nil {
+		t.Fatalf("unable to decode the audit message: %s", err)
+	}
+	if err := NewValidator(0, 0).Validate(msg, time.Now()); err != nil {
+		t.Errorf("unexpected validation error for an audit message without an entity: %s", err)
+	}
+
+	// The message can't be recorded until its persistent identifier is resolved.
+	errs, ok := ValidateIdentifier(msg).(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].Reason != ReasonUnresolvedID {
+		t.Errorf("expected an unresolved identifier error but got %v", errs)
+	}
+	msg.PID = "doi:10.5072/FK2TEST"
+	if err := ValidateIdentifier(msg); err != nil {
+		t.Errorf("unexpected validation error for an audit message with a PID: %s", err)
+	}
+}
+
+func TestAuditHandlers(t *testing.T) {
+	handlers := NewAuditDecoder(map[string]string{
+		"pep_api_data_obj_rename_post": "",
+		"pep_api_data_obj_unlink_post": "",
+	}).Handlers()
+	expected := map[string]bool{"read": true, "create": true}
+	if len(handlers) != len(expected) || !handlers["read"] || !handlers["create"] {
+		t.Errorf("unexpected audit handlers: %v", handlers)
+	}
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+)
+
+// Supported message schema versions.
+const (
+	SchemaVersion1 = "1"
+	SchemaVersion2 = "2"
+)
+
+// DefaultSchemaVersion is the schema version assumed for messages that don't specify one.
+const DefaultSchemaVersion = SchemaVersion1
+
+// HeaderSchemaVersion is the name of the AMQP header that may contain the schema version.
+const HeaderSchemaVersion = "schema-version"
+
+// ReasonUnsupportedSchema is the reason for rejecting a message with an unknown schema version.
+const ReasonUnsupportedSchema = "unsupported_schema"
+
+// DecoderFunc converts a message body in a specific schema version to a Message.
+type DecoderFunc func(body []byte) (*Message, error)
+
+// decoders maps each supported schema version to its decoder.
+var decoders = map[string]DecoderFunc{
+	SchemaVersion1: decodeV1,
+	SchemaVersion2: decodeV2,
+}
+
+// SchemaVersions returns every supported schema version.
+func SchemaVersions() []string {
+	var versions []string
+	for version := range decoders {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// messageV2 is the wire format of version 2 messages, which describe the source and destination of moves along with
+// the size and checksum of the data object.
+type messageV2 struct {
+	Author          *User      `json:"author"`
+	Entity          string     `json:"entity"`
+	Path            string     `json:"path"`
+	SourcePath      string     `json:"source_path"`
+	DestinationPath string     `json:"destination_path"`
+	Size            *int64     `json:"size"`
+	Checksum        string     `json:"checksum"`
+	Timestamp       *Timestamp `json:"timestamp,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	IPAddress       string     `json:"ip_address,omitempty"`
+}
+
+// malformed returns a validation error indicating that a message body couldn't be parsed.
+func malformed(err error) error {
+	var errs ValidationErrors
+	errs.add("", ReasonMalformed, "unable to parse the message: %s", err)
+	return errs
+}
+
+// decodeV1 decodes a version 1 message, which contains the author, entity, path and timestamp.
+func decodeV1(body []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, malformed(err)
+	}
+	return &msg, nil
+}
+
+// decodeV2 decodes a version 2 message. The destination path takes precedence over the path if both are present.
+func decodeV2(body []byte) (*Message, error) {
+	var wire messageV2
+	if err := json.Unmarshal(body, &wire); err != nil {
+		return nil, malformed(err)
+	}
+
+	msg := &Message{
+		Author:     wire.Author,
+		Entity:     wire.Entity,
+		Path:       wire.Path,
+		SourcePath: wire.SourcePath,
+		Size:       wire.Size,
+		Checksum:   wire.Checksum,
+		Timestamp:  wire.Timestamp,
+		UserAgent:  wire.UserAgent,
+		IPAddress:  wire.IPAddress,
+	}
+	if wire.DestinationPath != "" {
+		msg.Path = wire.DestinationPath
+	}
+	return msg, nil
+}
+
+// normalizeVersion converts a schema version from a header or message body to a string.
+func normalizeVersion(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// bodyVersion extracts the schema version from a message body. An empty string is returned if the body doesn't
+// specify a version or can't be parsed; parsing errors are reported by the decoder.
+func bodyVersion(body []byte) string {
+	var probe struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil || len(probe.SchemaVersion) == 0 {
+		return ""
+	}
+	return normalizeVersion(string(bytes.Trim(probe.SchemaVersion, `"`)))
+}
+
+// SchemaVersion determines the schema version of a message. The version parameter of the content type takes
+// precedence, followed by the schema-version header and the schema_version field in the message body. The default
+// version is returned if none of these specify a version.
+func SchemaVersion(contentType string, headers map[string]interface{}, body []byte) string {
+	if contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil && params["version"] != "" {
+			return params["version"]
+		}
+	}
+	if version := normalizeVersion(headers[HeaderSchemaVersion]); version != "" {
+		return version
+	}
+	if version := bodyVersion(body); version != "" {
+		return version
+	}
+	return DefaultSchemaVersion
+}
+
+// DecodeEnvelope decodes a message using the decoder for its schema version, which is determined from the content
+// type, headers and body of the message. The path in the decoded message is canonicalized. The returned error is a
+// ValidationErrors if the message can't be decoded.
+func DecodeEnvelope(contentType string, headers map[string]interface{}, body []byte) (*Message, error) {
+	version := SchemaVersion(contentType, headers, body)
+	decoder := decoders[version]
+	if decoder == nil {
+		var errs ValidationErrors
+		errs.add("schema_version", ReasonUnsupportedSchema, "unsupported schema version %q; expected one of: %s",
+			version, strings.Join(SchemaVersions(), ", "))
+		return nil, errs
+	}
+
+	msg, err := decoder(body)
+	if err != nil {
+		return nil, err
+	}
+	msg.SchemaVersion = version
+	msg.Path = CanonicalPath(msg.Path)
+	msg.SourcePath = CanonicalPath(msg.SourcePath)
+	return msg, nil
+}
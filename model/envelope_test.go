@@ -0,0 +1,94 @@
+package model
+
+import (
+	"testing"
+)
+
+var versionTwo = []byte(`
+{
+  "schema_version": 2,
+  "author": {
+    "name": "nobody",
+    "zone": "nowhere"
+  },
+  "entity": "fakeid",
+  "source_path": "/foo//baz",
+  "destination_path": "/foo/bar/",
+  "size": 1024,
+  "checksum": "sha2:1B2M2Y8AsgTpgAmY7PhCfg==",
+  "timestamp": "2017-10-06.15:07:37"
+}
+`)
+
+func TestSchemaVersion(t *testing.T) {
+	expected := []struct {
+		contentType string
+		headers     map[string]interface{}
+		body        []byte
+		version     string
+	}{
+		{"", nil, noTimestamp, SchemaVersion1},
+		{"application/json", nil, noTimestamp, SchemaVersion1},
+		{"", nil, versionTwo, SchemaVersion2},
+		{"", nil, []byte(`{"schema_version": "2"}`), SchemaVersion2},
+		{"", map[string]interface{}{HeaderSchemaVersion: int32(2)}, noTimestamp, SchemaVersion2},
+		{"", map[string]interface{}{HeaderSchemaVersion: "3"}, versionTwo, "3"},
+		{"application/vnd.cyverse.data-event+json; version=2", nil, noTimestamp, SchemaVersion2},
+		{
+			"application/vnd.cyverse.data-event+json; version=1",
+			map[string]interface{}{HeaderSchemaVersion: "2"},
+			versionTwo,
+			SchemaVersion1,
+		},
+	}
+	for _, tc := range expected {
+		if version := SchemaVersion(tc.contentType, tc.headers, tc.body); version != tc.version {
+			t.Errorf("SchemaVersion(%q, %v, %s): expected %s but got %s",
+				tc.contentType, tc.headers, tc.body, tc.version, version)
+		}
+	}
+}
+
+func TestVersionTwo(t *testing.T) {
+	msg, err := Decode(versionTwo)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+
+	validateCommonFields(t, msg)
+	if msg.SchemaVersion != SchemaVersion2 {
+		t.Errorf("expected schema version 2 but got %s", msg.SchemaVersion)
+	}
+	if msg.SourcePath != "/foo/baz" {
+		t.Errorf("expected source path `/foo/baz` but got `%s`", msg.SourcePath)
+	}
+	if msg.Size == nil || *msg.Size != 1024 {
+		t.Errorf("expected size 1024 but got %v", msg.Size)
+	}
+	if msg.Checksum != "sha2:1B2M2Y8AsgTpgAmY7PhCfg==" {
+		t.Errorf("unexpected checksum: %s", msg.Checksum)
+	}
+	if msg.Timestamp == nil {
+		t.Error("no timestamp extracted from message")
+	}
+}
+
+func TestVersionOne(t *testing.T) {
+	msg, err := DecodeEnvelope("application/json", nil, noTimestamp)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+
+	validateCommonFields(t, msg)
+	if msg.SchemaVersion != SchemaVersion1 || msg.SourcePath != "" || msg.Size != nil {
+		t.Errorf("unexpected fields in version 1 message: %+v", msg)
+	}
+}
+
+func TestUnsupportedSchema(t *testing.T) {
+	_, err := DecodeEnvelope("", map[string]interface{}{HeaderSchemaVersion: "99"}, noTimestamp)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].Reason != ReasonUnsupportedSchema {
+		t.Errorf("expected an unsupported schema error but got %v", err)
+	}
+}
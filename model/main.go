@@ -2,7 +2,10 @@ package model
 
 import (
 	"encoding/json"
+	"path"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // User represents an iRODS qualified username.
@@ -11,11 +14,67 @@ type User struct {
 	Zone string `json:"zone"`
 }
 
+// Identity describes the user who caused an event as it's stored in the event log. Each field may be a raw value, a
+// keyed hash or empty, depending on how the indexer is configured.
+type Identity struct {
+	UserName string
+	UserZone string
+	Subject  string
+}
+
 // Timestamp represents the time an event occurred.
 type Timestamp time.Time
 
-// ReferenceTime represents the timestamp format used by the service.
-const ReferenceTime = "\"2006-01-02.15:04:05\""
+// ReferenceLayout is the layout of the timestamps in messages sent by iRODS.
+const ReferenceLayout = "2006-01-02.15:04:05"
+
+// DefaultTimestampLayouts lists the timestamp layouts that are accepted by default, in the order in which they're
+// tried. Fractional seconds are accepted after the seconds field in every layout.
+var DefaultTimestampLayouts = []string{ReferenceLayout, time.RFC3339Nano}
+
+// TimestampParser parses timestamps using a list of accepted layouts. Timestamps that don't include a time zone are
+// interpreted in the source time zone. Parsed timestamps are always converted to UTC.
+type TimestampParser struct {
+	layouts  []string
+	location *time.Location
+}
+
+// NewTimestampParser creates a new TimestampParser. The default layouts are used if no layouts are provided, and UTC
+// is used if the location is nil.
+func NewTimestampParser(layouts []string, location *time.Location) *TimestampParser {
+	if len(layouts) == 0 {
+		layouts = DefaultTimestampLayouts
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &TimestampParser{layouts: layouts, location: location}
+}
+
+// Parse parses a timestamp using each of the accepted layouts in turn. The error from the first layout is returned
+// if the timestamp doesn't match any of them.
+func (p *TimestampParser) Parse(value string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range p.layouts {
+		t, err := time.ParseInLocation(layout, value, p.location)
+		if err == nil {
+			return t.UTC(), nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// timestampParser is the parser used when timestamps are decoded from messages.
+var timestampParser = NewTimestampParser(nil, nil)
+
+// SetTimestampParser replaces the parser used when timestamps are decoded from messages. It should only be called
+// during initialization, before any messages are decoded.
+func SetTimestampParser(p *TimestampParser) {
+	timestampParser = p
+}
 
 // CurrentTimestamp returns a timestamp representing the current time.
 func CurrentTimestamp() *Timestamp {
@@ -31,30 +90,142 @@ func (ts *Timestamp) UnmarshalJSON(value []byte) error {
 		return nil
 	}
 
+	// Extract the string from the JSON value.
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return err
+	}
+
 	// Parse the timestamp.
-	t, err := time.Parse(ReferenceTime, string(value))
+	t, err := timestampParser.Parse(s)
 	*ts = Timestamp(t)
 	return err
 }
 
+// Timestamp sources, which indicate where the timestamp of an event was obtained.
+const (
+	TimestampSourceMessage  = "message"
+	TimestampSourceDelivery = "delivery"
+	TimestampSourceReceived = "received"
+)
+
+// Root roles, which describe the kind of object stored beneath a repository root. Usage reporting counts reads of
+// data objects as downloads and reads of metadata documents and resource maps as views.
+const (
+	RoleData        = "data"
+	RoleMetadata    = "metadata"
+	RoleResourceMap = "resource-map"
+)
+
+// Roles lists every root role.
+var Roles = []string{RoleData, RoleMetadata, RoleResourceMap}
+
+// IsRole returns true if a string is a known root role.
+func IsRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
 // ToTime conversts a timestamp to a time pointer.
 func (ts *Timestamp) ToTime() *time.Time {
 	return (*time.Time)(ts)
 }
 
-// Message represents an event message sent from iRODS.
+// Message represents an event message sent from iRODS. Messages in every supported schema version are normalized to
+// this structure before they're processed. The JSON field names are those used by version 1 messages.
 type Message struct {
 	Author    *User      `json:"author"`
 	Entity    string     `json:"entity"`
 	Path      string     `json:"path"`
 	Timestamp *Timestamp `json:"timestamp,omitempty"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	IPAddress string     `json:"ip_address,omitempty"`
+
+	// SchemaVersion is the schema version of the message. The remaining fields in this group are only provided by
+	// newer schema versions. SourcePath is the original path of a data object that was moved or renamed, Size is
+	// the size of the data object in bytes and Checksum is the checksum reported by iRODS. The size and checksum are
+	// recorded in the inventory.
+	SchemaVersion string `json:"-"`
+	SourcePath    string `json:"-"`
+	Size          *int64 `json:"-"`
+	Checksum      string `json:"-"`
+
+	// Handler is the name of the handler that should record the event. It's only set by decoders for messages that
+	// don't identify the handler by routing key, such as those published by the iRODS audit plugin.
+	Handler string `json:"-"`
+
+	// NodeID is the identifier of the DataONE member node that owns the path. It's assigned by the indexer when the
+	// path is matched against the repository roots rather than decoded from the message body.
+	NodeID string `json:"-"`
+
+	// EventTypes maps handler names to the event types that should be recorded in place of the defaults. Like NodeID,
+	// it's assigned by the indexer when the path is matched against the repository roots.
+	EventTypes map[string]string `json:"-"`
+
+	// Role is the role of the repository root containing the object. Like NodeID, it's assigned by the indexer when
+	// the path is matched against the repository roots.
+	Role string `json:"-"`
+
+	// Identity describes the author of the message in the form that's stored in the event log. It's assigned by the
+	// indexer.
+	Identity Identity `json:"-"`
+
+	// Robot indicates that the event was caused by an automated client. It's assigned by the indexer.
+	Robot bool `json:"-"`
+
+	// TimestampSource indicates where the timestamp was obtained. It's assigned by ResolveTimestamp.
+	TimestampSource string `json:"-"`
+
+	// PID is the DataONE persistent identifier of the object. It's assigned by the indexer, and the entity ID is
+	// recorded in its place if it's empty.
+	PID string `json:"-"`
+
+	// DatasetPath is the root directory of the dataset containing the object, and DatasetID is the persistent
+	// identifier of the dataset, or its path if the identifier couldn't be resolved. PackageEvent indicates that a
+	// package-level event should also be recorded for the dataset. They're assigned by the indexer.
+	DatasetPath  string `json:"-"`
+	DatasetID    string `json:"-"`
+	PackageEvent bool   `json:"-"`
+
+	// ArchiveID is the ID of the message in the raw message archive, or zero if the message wasn't archived. It's
+	// assigned by the indexer.
+	ArchiveID int64 `json:"-"`
 }
 
-// Decode converts a serialized JSON message to a structure.
-func Decode(body []byte) (*Message, error) {
-	var msg Message
-	if err := json.Unmarshal(body, &msg); err != nil {
-		return nil, err
+// ResolveTimestamp ensures that the message has a timestamp. The timestamp in the message body is used if there is
+// one. Otherwise, the AMQP delivery timestamp is used if it's not zero, and the time the message was received is used
+// as a last resort. Fallback timestamps are converted to UTC, as parsed timestamps are.
+func (msg *Message) ResolveTimestamp(deliveryTime, receivedTime time.Time) {
+	switch {
+	case msg.Timestamp != nil:
+		msg.TimestampSource = TimestampSourceMessage
+	case !deliveryTime.IsZero():
+		t := deliveryTime.UTC()
+		msg.Timestamp = (*Timestamp)(&t)
+		msg.TimestampSource = TimestampSourceDelivery
+	default:
+		t := receivedTime.UTC()
+		msg.Timestamp = (*Timestamp)(&t)
+		msg.TimestampSource = TimestampSourceReceived
 	}
-	return &msg, nil
+}
+
+// CanonicalPath converts a path to its canonical form so that equivalent paths compare equal. The path is converted
+// to Unicode normalization form C, duplicate slashes are collapsed, "." and ".." segments are resolved and trailing
+// slashes are removed. Empty paths are left empty.
+func CanonicalPath(p string) string {
+	if p == "" {
+		return p
+	}
+	return path.Clean(norm.NFC.String(p))
+}
+
+// Decode converts a serialized JSON message to a structure. The schema version is taken from the message body if it's
+// present. See DecodeEnvelope for details.
+func Decode(body []byte) (*Message, error) {
+	return DecodeEnvelope("", nil, body)
 }
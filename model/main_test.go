@@ -68,14 +68,14 @@ func TestTimestamp(t *testing.T) {
 	if msg.Timestamp == nil {
 		t.Fatal("no timestamp extracted from message")
 	}
-	expectedTimestamp := "\"2017-10-06.15:07:37\""
-	expectedTime, err := time.Parse(ReferenceTime, expectedTimestamp)
+	expectedTimestamp := "2017-10-06.15:07:37"
+	expectedTime, err := time.Parse(ReferenceLayout, expectedTimestamp)
 	if err != nil {
 		t.Fatalf("unable to parse expected timestamp: %s", err)
 	}
 	actualTime := time.Time(*msg.Timestamp)
 	if actualTime != expectedTime {
-		t.Errorf("expected timestamp of `%s` but got `%s`", expectedTimestamp, expectedTime.Format(ReferenceTime))
+		t.Errorf("expected timestamp of `%s` but got `%s`", expectedTimestamp, actualTime.Format(ReferenceLayout))
 	}
 }
 
@@ -102,3 +102,163 @@ func TestExtraFields(t *testing.T) {
 
 	validateCommonFields(t, msg)
 }
+
+var unnormalizedPath = []byte(`
+{
+  "author": {
+    "name": "nobody",
+    "zone": "nowhere"
+  },
+  "entity": "fakeid",
+  "path": "//foo/./baz/..//bar/"
+}
+`)
+
+func TestPathNormalization(t *testing.T) {
+	msg, err := Decode(unnormalizedPath)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+
+	validateCommonFields(t, msg)
+}
+
+func TestCanonicalPath(t *testing.T) {
+	expected := map[string]string{
+		"":                 "",
+		"/":                "/",
+		"/foo/bar":         "/foo/bar",
+		"/foo/bar/":        "/foo/bar",
+		"/foo//bar":        "/foo/bar",
+		"/foo/./bar":       "/foo/bar",
+		"/foo/baz/../bar":  "/foo/bar",
+		"/../foo/bar":      "/foo/bar",
+		"/foo/cafe\u0301":  "/foo/caf\u00e9",
+		"/foo/caf\u00e9":   "/foo/caf\u00e9",
+		"/foo/\u212bngstr": "/foo/\u00c5ngstr",
+	}
+	for path, want := range expected {
+		if got := CanonicalPath(path); got != want {
+			t.Errorf("CanonicalPath(%q): expected %q but got %q", path, want, got)
+		}
+	}
+}
+
+var withClient = []byte(`
+{
+  "author": {
+    "name": "nobody",
+    "zone": "nowhere"
+  },
+  "entity": "fakeid",
+  "path": "/foo/bar",
+  "user_agent": "Mozilla/5.0 (compatible; Googlebot/2.1)",
+  "ip_address": "192.0.2.1"
+}
+`)
+
+func TestClientInfo(t *testing.T) {
+	msg, err := Decode(withClient)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+
+	validateCommonFields(t, msg)
+	if msg.UserAgent != "Mozilla/5.0 (compatible; Googlebot/2.1)" {
+		t.Errorf("unexpected user agent: %s", msg.UserAgent)
+	}
+	if msg.IPAddress != "192.0.2.1" {
+		t.Errorf("unexpected IP address: %s", msg.IPAddress)
+	}
+}
+
+func TestResolveTimestamp(t *testing.T) {
+	deliveryTime := time.Date(2018, time.March, 20, 15, 4, 5, 0, time.UTC)
+	receivedTime := deliveryTime.Add(time.Second)
+
+	// The message timestamp takes precedence.
+	msg, err := Decode(withTimestamp)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+	messageTime := *msg.Timestamp.ToTime()
+	msg.ResolveTimestamp(deliveryTime, receivedTime)
+	if msg.TimestampSource != TimestampSourceMessage || !msg.Timestamp.ToTime().Equal(messageTime) {
+		t.Errorf("expected the message timestamp but got %s from %s", msg.Timestamp.ToTime(), msg.TimestampSource)
+	}
+
+	// The delivery timestamp is used if the message doesn't have one.
+	msg, err = Decode(noTimestamp)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+	msg.ResolveTimestamp(deliveryTime, receivedTime)
+	if msg.TimestampSource != TimestampSourceDelivery || !msg.Timestamp.ToTime().Equal(deliveryTime) {
+		t.Errorf("expected the delivery timestamp but got %s from %s", msg.Timestamp.ToTime(), msg.TimestampSource)
+	}
+
+	// The time the message was received is used as a last resort.
+	msg, err = Decode(noTimestamp)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+	msg.ResolveTimestamp(time.Time{}, receivedTime)
+	if msg.TimestampSource != TimestampSourceReceived || !msg.Timestamp.ToTime().Equal(receivedTime) {
+		t.Errorf("expected the received time but got %s from %s", msg.Timestamp.ToTime(), msg.TimestampSource)
+	}
+
+	// Fallback timestamps are converted to UTC.
+	phoenix := time.FixedZone("MST", -7*60*60)
+	for _, times := range [][2]time.Time{
+		{deliveryTime.In(phoenix), receivedTime},
+		{time.Time{}, receivedTime.In(phoenix)},
+	} {
+		msg, err = Decode(noTimestamp)
+		if err != nil {
+			t.Fatalf("error encountered while decoding message: %s", err)
+		}
+		msg.ResolveTimestamp(times[0], times[1])
+		if loc := msg.Timestamp.ToTime().Location(); loc != time.UTC {
+			t.Errorf("expected a UTC %s timestamp but got %s", msg.TimestampSource, loc)
+		}
+	}
+}
+
+func TestTimestampParser(t *testing.T) {
+	phoenix := time.FixedZone("MST", -7*60*60)
+	p := NewTimestampParser(nil, phoenix)
+
+	expected := map[string]time.Time{
+		"2017-10-06.15:07:37":            time.Date(2017, time.October, 6, 22, 7, 37, 0, time.UTC),
+		"2017-10-06.15:07:37.25":         time.Date(2017, time.October, 6, 22, 7, 37, 250000000, time.UTC),
+		"2017-10-06T15:07:37Z":           time.Date(2017, time.October, 6, 15, 7, 37, 0, time.UTC),
+		"2017-10-06T15:07:37.5-04:00":    time.Date(2017, time.October, 6, 19, 7, 37, 500000000, time.UTC),
+		"2017-10-06T15:07:37.123456789Z": time.Date(2017, time.October, 6, 15, 7, 37, 123456789, time.UTC),
+	}
+	for value, want := range expected {
+		got, err := p.Parse(value)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %s", value, err)
+		} else if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("Parse(%q): expected %s but got %s", value, want, got)
+		}
+	}
+
+	if _, err := p.Parse("October 6, 2017"); err == nil {
+		t.Error("expected an error for an unsupported layout")
+	}
+}
+
+func TestSourceTimezone(t *testing.T) {
+	SetTimestampParser(NewTimestampParser(nil, time.FixedZone("MST", -7*60*60)))
+	defer SetTimestampParser(NewTimestampParser(nil, nil))
+
+	msg, err := Decode(withTimestamp)
+	if err != nil {
+		t.Fatalf("error encountered while decoding message: %s", err)
+	}
+	expected := time.Date(2017, time.October, 6, 22, 7, 37, 0, time.UTC)
+	if actual := *msg.Timestamp.ToTime(); !actual.Equal(expected) {
+		t.Errorf("expected timestamp of `%s` but got `%s`", expected, actual)
+	}
+}
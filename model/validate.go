@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Reasons for rejecting a message.
+const (
+	ReasonMalformed        = "malformed"
+	ReasonMissingField     = "missing_field"
+	ReasonInvalidEntity    = "invalid_entity"
+	ReasonRelativePath     = "relative_path"
+	ReasonInvalidTimestamp = "invalid_timestamp"
+	ReasonInvalidSize      = "invalid_size"
+	ReasonUnresolvedID     = "unresolved_identifier"
+)
+
+// uuidPattern matches the textual representation of a UUID.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidationError describes a single reason for rejecting a message.
+type ValidationError struct {
+	Field  string
+	Reason string
+	Detail string
+}
+
+// Error returns a human readable description of the validation error.
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Field, e.Reason, e.Detail)
+}
+
+// ValidationErrors lists every reason for rejecting a message. It implements the error interface so that all of the
+// reasons can be reported at once.
+type ValidationErrors []*ValidationError
+
+// Error returns a description of every validation error in the list.
+func (es ValidationErrors) Error() string {
+	descriptions := make([]string, len(es))
+	for i, e := range es {
+		descriptions[i] = e.Error()
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// Reasons returns the distinct reasons for rejecting the message, in the order in which they were first reported.
+func (es ValidationErrors) Reasons() []string {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, e := range es {
+		if !seen[e.Reason] {
+			reasons = append(reasons, e.Reason)
+			seen[e.Reason] = true
+		}
+	}
+	return reasons
+}
+
+// add appends a validation error to the list.
+func (es *ValidationErrors) add(field, reason, format string, args ...interface{}) {
+	*es = append(*es, &ValidationError{Field: field, Reason: reason, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Validator checks that decoded messages contain the information needed to record events.
+type Validator struct {
+	maxAge    time.Duration
+	maxFuture time.Duration
+}
+
+// NewValidator creates a new Validator. Timestamps older than maxAge or further than maxFuture in the future are
+// rejected. Either limit is disabled if it isn't positive.
+func NewValidator(maxAge, maxFuture time.Duration) *Validator {
+	return &Validator{maxAge: maxAge, maxFuture: maxFuture}
+}
+
+// Validate checks a message and returns ValidationErrors describing every problem that it finds, or nil if the
+// message is valid. The current time is used to check the timestamp.
+func (v *Validator) Validate(msg *Message, now time.Time) error {
+	var errs ValidationErrors
+
+	// Validate the entity ID. Messages from the iRODS audit plugin don't contain entity IDs.
+	if msg.Entity == "" && msg.SchemaVersion != SchemaIRODSAudit {
+		errs.add("entity", ReasonMissingField, "a value is required")
+	} else if msg.Entity != "" && !uuidPattern.MatchString(msg.Entity) {
+		errs.add("entity", ReasonInvalidEntity, "not a UUID: %s", msg.Entity)
+	}
+
+	// Validate the path.
+	if msg.Path == "" {
+		errs.add("path", ReasonMissingField, "a value is required")
+	} else if !strings.HasPrefix(msg.Path, "/") {
+		errs.add("path", ReasonRelativePath, "not an absolute path: %s", msg.Path)
+	}
+	if msg.SourcePath != "" && !strings.HasPrefix(msg.SourcePath, "/") {
+		errs.add("source_path", ReasonRelativePath, "not an absolute path: %s", msg.SourcePath)
+	}
+
+	// The author is optional, but it must be complete if it's present.
+	if msg.Author != nil && msg.Author.Name == "" {
+		errs.add("author.name", ReasonMissingField, "a value is required")
+	}
+
+	// The size is optional, but it can't be negative.
+	if msg.Size != nil && *msg.Size < 0 {
+		errs.add("size", ReasonInvalidSize, "must not be negative: %d", *msg.Size)
+	}
+
+	// Validate the timestamp.
+	if msg.Timestamp == nil {
+		errs.add("timestamp", ReasonMissingField, "a value is required")
+	} else {
+		t := *msg.Timestamp.ToTime()
+		if t.IsZero() || (v.maxAge > 0 && now.Sub(t) > v.maxAge) {
+			errs.add("timestamp", ReasonInvalidTimestamp, "too far in the past: %s", t.Format(time.RFC3339))
+		} else if v.maxFuture > 0 && t.Sub(now) > v.maxFuture {
+			errs.add("timestamp", ReasonInvalidTimestamp, "too far in the future: %s", t.Format(time.RFC3339))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateIdentifier checks that a message identifies the object that it refers to, either by entity ID or by a
+// resolved persistent identifier. It's called after persistent identifiers are resolved because messages from the
+// iRODS audit plugin don't contain entity IDs.
+func ValidateIdentifier(msg *Message) error {
+	if msg.Entity != "" || msg.PID != "" {
+		return nil
+	}
+	var errs ValidationErrors
+	errs.add("entity", ReasonUnresolvedID, "no persistent identifier was found for %s", msg.Path)
+	return errs
+}
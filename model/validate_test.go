@@ -0,0 +1,87 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// validMessage returns a message that passes validation.
+func validMessage(now time.Time) *Message {
+	return &Message{
+		Author:    &User{Name: "ipcdev", Zone: "iplant"},
+		Entity:    "F3579BF9-284B-4B3C-841B-F6E87D3F78EA",
+		Path:      "/iplant/home/shared/commons_repo/curated/foo.txt",
+		Timestamp: (*Timestamp)(&now),
+	}
+}
+
+func TestValidMessage(t *testing.T) {
+	now := time.Now()
+	if err := NewValidator(time.Hour, time.Minute).Validate(validMessage(now), now); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	now := time.Now()
+	v := NewValidator(time.Hour, time.Minute)
+
+	past := now.Add(-2 * time.Hour)
+	future := now.Add(2 * time.Minute)
+	negative := int64(-1)
+	expected := []struct {
+		modify func(*Message)
+		field  string
+		reason string
+	}{
+		{func(m *Message) { m.Entity = "" }, "entity", ReasonMissingField},
+		{func(m *Message) { m.Entity = "fakeid" }, "entity", ReasonInvalidEntity},
+		{func(m *Message) { m.Path = "" }, "path", ReasonMissingField},
+		{func(m *Message) { m.Path = "iplant/home" }, "path", ReasonRelativePath},
+		{func(m *Message) { m.SourcePath = "iplant/home" }, "source_path", ReasonRelativePath},
+		{func(m *Message) { m.Author = &User{Zone: "iplant"} }, "author.name", ReasonMissingField},
+		{func(m *Message) { m.Size = &negative }, "size", ReasonInvalidSize},
+		{func(m *Message) { m.Timestamp = nil }, "timestamp", ReasonMissingField},
+		{func(m *Message) { m.Timestamp = &Timestamp{} }, "timestamp", ReasonInvalidTimestamp},
+		{func(m *Message) { m.Timestamp = (*Timestamp)(&past) }, "timestamp", ReasonInvalidTimestamp},
+		{func(m *Message) { m.Timestamp = (*Timestamp)(&future) }, "timestamp", ReasonInvalidTimestamp},
+	}
+	for _, tc := range expected {
+		msg := validMessage(now)
+		tc.modify(msg)
+
+		err := v.Validate(msg, now)
+		errs, ok := err.(ValidationErrors)
+		if !ok || len(errs) != 1 {
+			t.Errorf("%s: expected a single validation error but got %v", tc.field, err)
+			continue
+		}
+		if errs[0].Field != tc.field || errs[0].Reason != tc.reason {
+			t.Errorf("%s: expected reason %s but got %s", tc.field, tc.reason, errs[0])
+		}
+	}
+}
+
+func TestMultipleReasons(t *testing.T) {
+	now := time.Now()
+	msg := validMessage(now)
+	msg.Entity = ""
+	msg.Path = ""
+	msg.Author.Name = ""
+
+	errs, ok := NewValidator(0, 0).Validate(msg, now).(ValidationErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected three validation errors but got %v", errs)
+	}
+	if reasons := errs.Reasons(); len(reasons) != 1 || reasons[0] != ReasonMissingField {
+		t.Errorf("unexpected rejection reasons: %v", reasons)
+	}
+}
+
+func TestMalformedMessage(t *testing.T) {
+	_, err := Decode([]byte("{"))
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].Reason != ReasonMalformed {
+		t.Errorf("expected a malformed message error but got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/cyverse-de/dataone-indexer/repository"
+)
+
+// match determines whether the object in a message is in the repository and which handler should record the event.
+// It returns the rule that admits the recorded path into the repository, the handler and false if the event should be
+// ignored. Moves are checked at both the source and the destination: a move into the repository is recorded as a
+// create, and a move out of the repository is recorded as a delete at the source path so that the inventory no longer
+// lists the object.
+func (s *indexerState) match(msg *model.Message, handler string) (*repository.Rule, string, bool) {
+	rule, ok := s.matcher.Match(msg.Path)
+	if handler != database.HandlerMove || msg.SourcePath == "" || msg.SourcePath == msg.Path {
+		return rule, handler, ok
+	}
+
+	sourceRule, sourceOK := s.matcher.Match(msg.SourcePath)
+	switch {
+	case ok && !sourceOK:
+		return rule, database.HandlerCreate, true
+	case !ok && sourceOK:
+		msg.Path = msg.SourcePath
+		return sourceRule, database.HandlerDelete, true
+	default:
+		return rule, handler, ok
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/logger"
+	"github.com/cyverse-de/dataone-indexer/metrics"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/cyverse-de/dataone-indexer/pid"
+	"github.com/spf13/viper"
+)
+
+// getPIDResolver builds the resolver used to look up persistent identifiers. Lookups are disabled unless pids.table
+// is set, in which case every event is recorded with the iRODS UUID.
+func getPIDResolver(cfg *viper.Viper, db *sql.DB) (*pid.Resolver, error) {
+	var lookup pid.Lookup
+	if table := cfg.GetString("pids.table"); table != "" {
+		pidTable, err := database.NewPIDTable(db, table)
+		if err != nil {
+			return nil, err
+		}
+		lookup = pidTable
+	}
+	return pid.NewResolver(lookup, cfg.GetInt("pids.cache-size"), cfg.GetDuration("pids.cache-ttl")), nil
+}
+
+// resolvePID assigns the persistent identifier of the object in a message. The identifier is left empty, so that the
+// iRODS UUID is recorded in its place, if it can't be resolved. Messages without entity IDs are assigned the UUID
+// recorded in the mapping for the path if there is one.
+func (svc *DataoneIndexer) resolvePID(ctx context.Context, msg *model.Message) {
+	if !svc.pids.Enabled() {
+		return
+	}
+
+	if msg.Entity == "" {
+		uuid, err := svc.pids.ResolveUUID(ctx, msg.Path)
+		if err != nil {
+			logger.Log.Warnf("unable to look up the UUID of %s: %s", msg.Path, err)
+		}
+		msg.Entity = uuid
+	}
+
+	id, ok, err := svc.pids.Resolve(ctx, msg.Entity, msg.Path)
+	if err != nil {
+		logger.Log.Warnf("unable to resolve the persistent identifier of %s: %s", msg.Path, err)
+	}
+	if !ok {
+		metrics.PIDFallbacks.Inc()
+		return
+	}
+	msg.PID = id
+}
+
+// attributeDataset assigns the dataset containing the object in a message. The dataset is identified by its
+// persistent identifier, or by its path if the identifier can't be resolved. Package-level events are only requested
+// for reads, and only once per dataset and user within the deduplication window.
+func (svc *DataoneIndexer) attributeDataset(ctx context.Context, state *indexerState, msg *model.Message, read bool) {
+	datasetPath, ok := state.datasets.DatasetRoot(msg.Path)
+	if !ok {
+		return
+	}
+
+	msg.DatasetPath = datasetPath
+	msg.DatasetID = datasetPath
+	msg.PackageEvent = read && state.packageEvents
+	if !svc.pids.Enabled() {
+		return
+	}
+
+	id, ok, err := svc.pids.Resolve(ctx, "", datasetPath)
+	if err != nil {
+		logger.Log.Warnf("unable to resolve the persistent identifier of %s: %s", datasetPath, err)
+	}
+	if ok {
+		msg.DatasetID = id
+	}
+}
+
+// dedupPackageEvent withdraws the request for a package-level event if the same user has read the dataset recently.
+func (svc *DataoneIndexer) dedupPackageEvent(ctx context.Context, msg *model.Message) {
+	if msg.PackageEvent && svc.isDuplicatePackageRead(ctx, msg) {
+		metrics.DuplicatePackageReads.Inc()
+		msg.PackageEvent = false
+	}
+}
@@ -0,0 +1,115 @@
+// Package pid resolves the DataONE persistent identifiers of repository objects.
+//
+// DataONE expects events to refer to objects by persistent identifier, which is usually a DOI or an identifier stored
+// in a metadata AVU, rather than by iRODS UUID. Identifiers are looked up by UUID or path using a Lookup, and the
+// results, including failures to find an identifier, are cached in a bounded LRU cache for a limited time so that
+// new mappings are eventually noticed.
+package pid
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/lru"
+)
+
+// Lookup is implemented by anything that can look up persistent identifiers.
+type Lookup interface {
+
+	// LookupPID returns the persistent identifier and iRODS UUID recorded for an object with a UUID or path. The UUID
+	// takes precedence if both match different objects. Empty strings are returned if no identifier is found, and the
+	// UUID is empty if the mapping doesn't record one.
+	LookupPID(ctx context.Context, uuid, path string) (string, string, error)
+}
+
+// cacheEntry is a cached lookup result.
+type cacheEntry struct {
+	pid     string
+	uuid    string
+	expires time.Time
+}
+
+// Resolver resolves persistent identifiers, caching the results.
+type Resolver struct {
+	lookup Lookup
+	ttl    time.Duration
+	cache  *lru.Cache
+	now    func() time.Time
+}
+
+// NewResolver creates a new Resolver. Lookups are disabled if lookup is nil, in which case every object falls back to
+// its UUID.
+func NewResolver(lookup Lookup, cacheSize int, ttl time.Duration) *Resolver {
+	return &Resolver{
+		lookup: lookup,
+		ttl:    ttl,
+		cache:  lru.New(cacheSize),
+		now:    time.Now,
+	}
+}
+
+// Enabled returns true if the resolver looks up persistent identifiers.
+func (r *Resolver) Enabled() bool {
+	return r.lookup != nil
+}
+
+// cacheKey returns the cache key for an object.
+func cacheKey(uuid, path string) string {
+	return uuid + "|" + path
+}
+
+// Resolve returns the persistent identifier for an object with a UUID and path. If no identifier is found, the UUID
+// is returned and the second return value is false. Lookup errors are returned along with the UUID, and the failed
+// lookup isn't cached.
+func (r *Resolver) Resolve(ctx context.Context, uuid, path string) (string, bool, error) {
+	entry, err := r.mapping(ctx, uuid, path)
+	if err != nil || entry == nil {
+		return uuid, false, err
+	}
+	return resolved(uuid, entry.pid)
+}
+
+// ResolveUUID returns the iRODS UUID recorded in the mapping for the object at a path. It's used for messages that
+// don't contain entity IDs. An empty string is returned if lookups are disabled or the mapping doesn't record a UUID.
+func (r *Resolver) ResolveUUID(ctx context.Context, path string) (string, error) {
+	entry, err := r.mapping(ctx, "", path)
+	if err != nil || entry == nil {
+		return "", err
+	}
+	return entry.uuid, nil
+}
+
+// mapping returns the mapping for an object with a UUID and path, using the cached result if there is one. Nil is
+// returned if lookups are disabled.
+func (r *Resolver) mapping(ctx context.Context, uuid, path string) (*cacheEntry, error) {
+	if r.lookup == nil {
+		return nil, nil
+	}
+
+	// Use the cached result if there is one.
+	key := cacheKey(uuid, path)
+	if value, ok := r.cache.Get(key); ok {
+		entry := value.(*cacheEntry)
+		if r.now().Before(entry.expires) {
+			return entry, nil
+		}
+		r.cache.Remove(key)
+	}
+
+	// Look up the identifier.
+	pid, mappedUUID, err := r.lookup.LookupPID(ctx, uuid, path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{pid: pid, uuid: mappedUUID, expires: r.now().Add(r.ttl)}
+	r.cache.Add(key, entry)
+	return entry, nil
+}
+
+// resolved returns the result of resolving an identifier, falling back to the UUID if the identifier is empty.
+func resolved(uuid, pid string) (string, bool, error) {
+	if pid == "" {
+		return uuid, false, nil
+	}
+	return pid, true, nil
+}
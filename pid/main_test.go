@@ -0,0 +1,101 @@
+package pid
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeLookup is a Lookup that counts calls and returns identifiers from a map keyed by UUID.
+type fakeLookup struct {
+	pids  map[string]string
+	calls int
+	err   error
+}
+
+// LookupPID returns the persistent identifier for an object. Objects looked up by path alone are given the UUID
+// "uuid-" followed by the path.
+func (l *fakeLookup) LookupPID(ctx context.Context, uuid, path string) (string, string, error) {
+	l.calls++
+	if uuid == "" {
+		return "doi:10.5072/FK3", "uuid-" + path, l.err
+	}
+	return l.pids[uuid], uuid, l.err
+}
+
+// TestResolve verifies that identifiers are resolved, cached and fall back to the UUID.
+func TestResolve(t *testing.T) {
+	lookup := &fakeLookup{pids: map[string]string{"uuid1": "doi:10.5072/FK2"}}
+	r := NewResolver(lookup, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		pid, ok, err := r.Resolve(context.Background(), "uuid1", "/path/1")
+		if err != nil || !ok || pid != "doi:10.5072/FK2" {
+			t.Errorf("unexpected result: %s, %t, %v", pid, ok, err)
+		}
+		pid, ok, err = r.Resolve(context.Background(), "uuid2", "/path/2")
+		if err != nil || ok || pid != "uuid2" {
+			t.Errorf("unexpected fallback result: %s, %t, %v", pid, ok, err)
+		}
+	}
+	if lookup.calls != 2 {
+		t.Errorf("expected 2 lookups but got %d", lookup.calls)
+	}
+}
+
+// TestExpiration verifies that cached results expire.
+func TestExpiration(t *testing.T) {
+	lookup := &fakeLookup{pids: map[string]string{}}
+	r := NewResolver(lookup, 10, time.Minute)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.Resolve(context.Background(), "uuid1", "/path/1")
+	lookup.pids["uuid1"] = "doi:10.5072/FK2"
+	if pid, _, _ := r.Resolve(context.Background(), "uuid1", "/path/1"); pid != "uuid1" {
+		t.Errorf("expected the cached fallback but got %s", pid)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if pid, _, _ := r.Resolve(context.Background(), "uuid1", "/path/1"); pid != "doi:10.5072/FK2" {
+		t.Errorf("expected the new identifier but got %s", pid)
+	}
+}
+
+// TestLookupError verifies that failed lookups fall back to the UUID and aren't cached.
+func TestLookupError(t *testing.T) {
+	lookup := &fakeLookup{err: fmt.Errorf("connection refused")}
+	r := NewResolver(lookup, 10, time.Minute)
+
+	pid, ok, err := r.Resolve(context.Background(), "uuid1", "/path/1")
+	if err == nil || ok || pid != "uuid1" {
+		t.Errorf("unexpected result: %s, %t, %v", pid, ok, err)
+	}
+	r.Resolve(context.Background(), "uuid1", "/path/1")
+	if lookup.calls != 2 {
+		t.Errorf("expected 2 lookups but got %d", lookup.calls)
+	}
+}
+
+// TestDisabled verifies that every object falls back to its UUID if lookups are disabled.
+func TestDisabled(t *testing.T) {
+	r := NewResolver(nil, 10, time.Minute)
+	if pid, ok, err := r.Resolve(context.Background(), "uuid1", "/path/1"); err != nil || ok || pid != "uuid1" {
+		t.Errorf("unexpected result: %s, %t, %v", pid, ok, err)
+	}
+}
+
+// TestResolveUUID verifies that UUIDs can be looked up by path for messages that don't contain entity IDs.
+func TestResolveUUID(t *testing.T) {
+	lookup := &fakeLookup{pids: map[string]string{}}
+	r := NewResolver(lookup, 10, time.Minute)
+	if uuid, err := r.ResolveUUID(context.Background(), "/path/1"); err != nil || uuid != "uuid-/path/1" {
+		t.Errorf("unexpected result: %s, %v", uuid, err)
+	}
+
+	disabled := NewResolver(nil, 10, time.Minute)
+	if uuid, err := disabled.ResolveUUID(context.Background(), "/path/1"); err != nil || uuid != "" {
+		t.Errorf("unexpected result: %s, %v", uuid, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/logger"
+	"github.com/cyverse-de/dataone-indexer/metrics"
+	"github.com/cyverse-de/dataone-indexer/model"
+	"github.com/streadway/amqp"
+)
+
+// Headers added to messages that are published to the dead-letter exchange.
+const (
+	headerRejectionReasons = "x-rejection-reasons"
+	headerRejectionDetail  = "x-rejection-detail"
+)
+
+// rejectMessage counts and logs a message that failed validation and publishes it to the dead-letter exchange if one
+// is configured. The original headers are retained, and headers describing why the message was rejected are added.
+// It returns true if the message was dead-lettered.
+func (svc *DataoneIndexer) rejectMessage(ch *amqp.Channel, delivery amqp.Delivery, errs model.ValidationErrors) bool {
+	reasons := errs.Reasons()
+	for _, reason := range reasons {
+		metrics.MessagesRejected.WithLabelValues(reason).Inc()
+	}
+	logger.Log.Errorf("rejected message (%s): %s", delivery.Body, errs)
+
+	if svc.deadLetterExchange == "" {
+		return false
+	}
+
+	headers := amqp.Table{}
+	for name, value := range delivery.Headers {
+		headers[name] = value
+	}
+	headers[headerRejectionReasons] = strings.Join(reasons, ",")
+	headers[headerRejectionDetail] = errs.Error()
+
+	err := ch.Publish(svc.deadLetterExchange, delivery.RoutingKey, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  delivery.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    delivery.Timestamp,
+		Body:         delivery.Body,
+	})
+	if err != nil {
+		logger.Log.Errorf("unable to publish the rejected message to the dead-letter exchange: %s", err)
+		return false
+	}
+	return true
+}
+
+// acknowledge acknowledges a message that was either processed successfully or dead-lettered. Any other message is
+// negatively acknowledged without being requeued.
+func (svc *DataoneIndexer) acknowledge(ch *amqp.Channel, delivery amqp.Delivery, err error) {
+	if errs, ok := err.(model.ValidationErrors); ok {
+		if svc.rejectMessage(ch, delivery, errs) {
+			err = nil
+		}
+	} else if err != nil {
+		logger.Log.Errorf("failed to process message: %s", err)
+	}
+
+	if err == nil {
+		metrics.Acknowledgements.WithLabelValues("ack").Inc()
+		if err := delivery.Ack(false); err != nil {
+			logger.Log.Warnf("unable to acknowledge AMQP message: %s", err)
+		}
+	} else {
+		metrics.Acknowledgements.WithLabelValues("nack").Inc()
+		if err := delivery.Nack(false, false); err != nil {
+			logger.Log.Warnf("unable to negatively acknowledge AMQP message: %s", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cyverse-de/configurate"
+	"github.com/cyverse-de/dataone-indexer/config"
+	"github.com/cyverse-de/dataone-indexer/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay is the amount of time to wait for file system activity to settle before reloading the configuration.
+// Configuration updates frequently generate several file system events in quick succession; Kubernetes, for example,
+// replaces mounted secrets by swapping symbolic links.
+const reloadDelay = 2 * time.Second
+
+// reloadConfig loads the configuration file and passes the new state to the message processing loop. The current
+// state is retained if the configuration can't be loaded or contains problems.
+func (svc *DataoneIndexer) reloadConfig(path string) {
+	logger.Log.Infof("reloading the configuration from %s", path)
+
+	cfg, err := configurate.InitDefaults(path, config.Default)
+	if err != nil {
+		logger.Log.Errorf("unable to reload the configuration: %s", err)
+		return
+	}
+
+	if problems := config.Validate(cfg); len(problems) > 0 {
+		for _, problem := range problems {
+			logger.Log.Errorf("configuration problem: %s", problem)
+		}
+		logger.Log.Errorf("not reloading the configuration because it contains %d problem(s)", len(problems))
+		return
+	}
+
+	state, err := svc.newIndexerState(cfg)
+	if err != nil {
+		logger.Log.Errorf("unable to reload the configuration: %s", err)
+		return
+	}
+	svc.reloads <- state
+}
+
+// watchForReloads reloads the configuration whenever the configuration file changes or the process receives SIGHUP.
+// Only the repository roots, path rules, member nodes, user exclusions, subject mappings, identity mode and keys, robot
+// detection settings and routing keys are reloaded. Changes to any other settings require a restart.
+func (svc *DataoneIndexer) watchForReloads(path string) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	// Watch the directory containing the configuration file if we're supposed to. The directory is watched rather than
+	// the file itself so that changes made by replacing the file are detected.
+	var events chan fsnotify.Event
+	var errors chan error
+	if svc.cfg.GetBool("reload.watch-config") {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			logger.Log.Errorf("unable to watch the configuration file: %s", err)
+		} else if err := watcher.Add(filepath.Dir(path)); err != nil {
+			logger.Log.Errorf("unable to watch the configuration file: %s", err)
+			watcher.Close()
+		} else {
+			defer watcher.Close()
+			events, errors = watcher.Events, watcher.Errors
+		}
+	}
+
+	// Wait for the file system to settle after each change before reloading.
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+
+	for {
+		select {
+		case <-hangups:
+			logger.Log.Info("received SIGHUP")
+			svc.reloadConfig(path)
+
+		case event := <-events:
+			logger.Log.Debugf("configuration directory event: %s", event)
+			timer.Reset(reloadDelay)
+
+		case err := <-errors:
+			logger.Log.Warnf("error watching the configuration file: %s", err)
+
+		case <-timer.C:
+			svc.reloadConfig(path)
+		}
+	}
+}
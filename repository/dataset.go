@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+)
+
+// DatasetRule derives the root directory of a dataset from the path of a file within it. The dataset root is the
+// directory formed by the first Depth path segments beneath Root, so with a depth of 1 every top-level directory
+// beneath Root is a dataset.
+type DatasetRule struct {
+	Root   string
+	Depth  int
+	prefix string
+}
+
+// NewDatasetRule creates a new dataset rule. The root is canonicalized so that it matches canonicalized message paths.
+func NewDatasetRule(root string, depth int) (*DatasetRule, error) {
+	if !strings.HasPrefix(root, "/") {
+		return nil, fmt.Errorf("dataset rules must contain an absolute path: %s", root)
+	}
+	if depth <= 0 {
+		return nil, fmt.Errorf("the dataset depth must be positive: %d", depth)
+	}
+	root = model.CanonicalPath(root)
+	return &DatasetRule{Root: root, Depth: depth, prefix: addLastSlash(root)}, nil
+}
+
+// DatasetRoot returns the root directory of the dataset containing a path. The second return value is false if the
+// path isn't beneath the rule's root or if it's not inside a directory at the dataset depth.
+func (r *DatasetRule) DatasetRoot(path string) (string, bool) {
+	if !strings.HasPrefix(path, r.prefix) {
+		return "", false
+	}
+	segments := strings.Split(strings.TrimPrefix(path, r.prefix), "/")
+	if len(segments) <= r.Depth {
+		return "", false
+	}
+	return r.prefix + strings.Join(segments[:r.Depth], "/"), true
+}
+
+// DatasetFinder finds the datasets that contain paths.
+type DatasetFinder struct {
+	rules []*DatasetRule
+}
+
+// NewDatasetFinder creates a new DatasetFinder. When the roots of several rules contain a path, the rule with the
+// most specific root is used.
+func NewDatasetFinder(rules []*DatasetRule) *DatasetFinder {
+	sorted := make([]*DatasetRule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].prefix) > len(sorted[j].prefix)
+	})
+	return &DatasetFinder{rules: sorted}
+}
+
+// DatasetRoot returns the root directory of the dataset containing a path. The second return value is false if the
+// path isn't in a dataset.
+func (f *DatasetFinder) DatasetRoot(path string) (string, bool) {
+	for _, rule := range f.rules {
+		if strings.HasPrefix(path, rule.prefix) {
+			return rule.DatasetRoot(path)
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,45 @@
+package repository
+
+import "testing"
+
+// TestDatasetRoot verifies that dataset roots are derived from file paths using the most specific rule.
+func TestDatasetRoot(t *testing.T) {
+	var rules []*DatasetRule
+	for root, depth := range map[string]int{
+		"/iplant/home/shared/commons_repo/curated":           1,
+		"/iplant/home/shared/commons_repo/curated/projects/": 2,
+	} {
+		rule, err := NewDatasetRule(root, depth)
+		if err != nil {
+			t.Fatalf("unable to create the dataset rule: %s", err)
+		}
+		rules = append(rules, rule)
+	}
+	f := NewDatasetFinder(rules)
+
+	const curated = "/iplant/home/shared/commons_repo/curated"
+	expected := map[string]string{
+		curated + "/study_2018/data/foo.txt":                    curated + "/study_2018",
+		curated + "/study_2018/foo.txt":                         curated + "/study_2018",
+		curated + "/projects/a/b/c/foo.txt":                     curated + "/projects/a/b",
+		curated + "/projects/a/foo.txt":                         "",
+		curated + "/foo.txt":                                    "",
+		"/iplant/home/shared/commons_repo/curated_metadata/a/b": "",
+	}
+	for path, want := range expected {
+		got, ok := f.DatasetRoot(path)
+		if ok != (want != "") || got != want {
+			t.Errorf("DatasetRoot(%s): expected %q but got %q", path, want, got)
+		}
+	}
+}
+
+// TestInvalidDatasetRule verifies that relative roots and non-positive depths are rejected.
+func TestInvalidDatasetRule(t *testing.T) {
+	if _, err := NewDatasetRule("relative/path", 1); err == nil {
+		t.Error("expected an error for a relative root")
+	}
+	if _, err := NewDatasetRule("/iplant/home/shared/commons_repo/curated", 0); err == nil {
+		t.Error("expected an error for a depth of zero")
+	}
+}
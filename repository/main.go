@@ -0,0 +1,266 @@
+// Package repository determines whether or not paths in the data store belong to a DataONE repository.
+//
+// Paths are expected to be in the canonical form produced by model.CanonicalPath, and repository roots and rules are
+// normalized in the same way when they're parsed.
+//
+// A path belongs to the repository if it matches at least one include rule and no exclude rules. Each repository root
+// is an include rule that matches every path beneath the root. Additional rules can be specified using the following
+// syntax:
+//
+//	prefix:/some/path    matches every path beneath /some/path
+//	glob:pattern         matches paths using a glob pattern
+//	regex:expression     matches paths using a regular expression
+//
+// Rules without a recognized type prefix are treated as glob patterns. In glob patterns, `*` matches any sequence of
+// characters within a single path segment, `?` matches any single character other than a slash, `[...]` matches a
+// character class and `**` matches any sequence of characters, including slashes. A glob pattern that doesn't begin
+// with a slash can match starting at any segment, and a glob pattern that matches a directory also matches everything
+// beneath the directory, so `.staging` matches every path within any directory called `.staging`.
+//
+// Rules are indexed in a trie keyed by the path segments in the literal prefix of each rule, so only the rules that
+// could possibly match a path are evaluated. This keeps matching efficient even for large rule sets.
+package repository
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cyverse-de/dataone-indexer/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Rule types.
+const (
+	RulePrefix = "prefix"
+	RuleGlob   = "glob"
+	RuleRegex  = "regex"
+)
+
+// Owner describes the DataONE member node that owns the paths admitted by an include rule, along with the role of the
+// objects beneath the paths.
+type Owner struct {
+	NodeID     string
+	EventTypes map[string]string
+	Role       string
+}
+
+// Rule represents a single path matching rule. Include rules have an owner; exclude rules don't.
+type Rule struct {
+	Type    string
+	Pattern string
+	Owner   *Owner
+	prefix  string
+	re      *regexp.Regexp
+}
+
+// String returns the textual representation of a rule.
+func (r *Rule) String() string {
+	return r.Type + ":" + r.Pattern
+}
+
+// Matches returns true if a path matches a rule.
+func (r *Rule) Matches(path string) bool {
+	if r.Type == RulePrefix {
+		return strings.HasPrefix(path, r.prefix)
+	}
+	return r.re.MatchString(path)
+}
+
+// indexPrefix returns the directory portion of the literal prefix that every matching path must begin with. An empty
+// string is returned if a matching path can begin with anything.
+func (r *Rule) indexPrefix() string {
+	return r.prefix[:strings.LastIndex(r.prefix, "/")+1]
+}
+
+// addLastSlash adds a trailing slash to a path if it's not there already.
+func addLastSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path
+	}
+	return path + "/"
+}
+
+// NewPrefixRule creates a rule that matches every path beneath a directory. The directory is canonicalized so that it
+// matches canonicalized message paths.
+func NewPrefixRule(dir string) (*Rule, error) {
+	if !strings.HasPrefix(dir, "/") {
+		return nil, fmt.Errorf("prefix rules must contain an absolute path: %s", dir)
+	}
+	dir = model.CanonicalPath(dir)
+	return &Rule{Type: RulePrefix, Pattern: dir, prefix: addLastSlash(dir)}, nil
+}
+
+// globToRegexp converts a glob pattern to an equivalent regular expression.
+func globToRegexp(pattern string) (string, error) {
+	var sb strings.Builder
+
+	// Patterns that don't begin with a slash may match starting at any path segment.
+	sb.WriteString("^")
+	if !strings.HasPrefix(pattern, "/") {
+		sb.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class in glob pattern: %s", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// A pattern that matches a directory also matches everything beneath it.
+	sb.WriteString("(?:/.*)?$")
+	return sb.String(), nil
+}
+
+// NewGlobRule creates a rule that matches paths using a glob pattern. The pattern is converted to Unicode
+// normalization form C so that it matches canonicalized message paths.
+func NewGlobRule(pattern string) (*Rule, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty glob pattern")
+	}
+	pattern = norm.NFC.String(pattern)
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s: %s", pattern, err)
+	}
+
+	// Only patterns that begin with a slash have a literal prefix.
+	var prefix string
+	if strings.HasPrefix(pattern, "/") {
+		if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+			prefix = pattern[:i]
+		} else {
+			prefix = pattern
+		}
+	}
+
+	return &Rule{Type: RuleGlob, Pattern: pattern, prefix: prefix, re: re}, nil
+}
+
+// NewRegexRule creates a rule that matches paths using a regular expression. The expression must be anchored at the
+// beginning with ^ in order to be indexed by its literal prefix. Like glob patterns, regular expressions are converted
+// to Unicode normalization form C.
+func NewRegexRule(expr string) (*Rule, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("empty regular expression")
+	}
+	expr = norm.NFC.String(expr)
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %s: %s", expr, err)
+	}
+
+	var prefix string
+	if strings.HasPrefix(expr, "^") {
+		prefix, _ = re.LiteralPrefix()
+	}
+
+	return &Rule{Type: RuleRegex, Pattern: expr, prefix: prefix, re: re}, nil
+}
+
+// ParseRule parses a rule specification.
+func ParseRule(spec string) (*Rule, error) {
+	switch {
+	case strings.HasPrefix(spec, RulePrefix+":"):
+		return NewPrefixRule(strings.TrimPrefix(spec, RulePrefix+":"))
+	case strings.HasPrefix(spec, RuleGlob+":"):
+		return NewGlobRule(strings.TrimPrefix(spec, RuleGlob+":"))
+	case strings.HasPrefix(spec, RuleRegex+":"):
+		return NewRegexRule(strings.TrimPrefix(spec, RuleRegex+":"))
+	default:
+		return NewGlobRule(spec)
+	}
+}
+
+// Matcher determines whether or not paths belong to the repository.
+type Matcher struct {
+	include *trie
+	exclude *trie
+}
+
+// NewMatcher creates a matcher with a list of exclude rule specifications. Include rules are added separately so that
+// each one can be associated with the member node that owns the paths that it admits.
+func NewMatcher(exclude []string) (*Matcher, error) {
+	m := &Matcher{include: newTrie(), exclude: newTrie()}
+
+	for _, spec := range exclude {
+		rule, err := ParseRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude rule: %s", err)
+		}
+		m.exclude.add(rule)
+	}
+
+	return m, nil
+}
+
+// AddRoot adds a repository root owned by a member node to a matcher.
+func (m *Matcher) AddRoot(dir string, owner *Owner) error {
+	rule, err := NewPrefixRule(dir)
+	if err != nil {
+		return fmt.Errorf("invalid repository root: %s", err)
+	}
+	rule.Owner = owner
+	m.include.add(rule)
+	return nil
+}
+
+// AddInclude adds an include rule owned by a member node to a matcher.
+func (m *Matcher) AddInclude(spec string, owner *Owner) error {
+	rule, err := ParseRule(spec)
+	if err != nil {
+		return fmt.Errorf("invalid include rule: %s", err)
+	}
+	rule.Owner = owner
+	m.include.add(rule)
+	return nil
+}
+
+// Explain returns the include rule that a path matches and the exclude rule that a path matches. Either rule may be
+// nil. When several include rules match, the most specific one is returned.
+func (m *Matcher) Explain(path string) (included, excluded *Rule) {
+	return m.include.match(path), m.exclude.match(path)
+}
+
+// Match returns the include rule that admits a path into the repository. The second return value is false if the
+// path isn't in the repository.
+func (m *Matcher) Match(path string) (*Rule, bool) {
+	included, excluded := m.Explain(path)
+	if included == nil || excluded != nil {
+		return nil, false
+	}
+	return included, true
+}
+
+// Matches returns true if a path is in the repository.
+func (m *Matcher) Matches(path string) bool {
+	_, ok := m.Match(path)
+	return ok
+}
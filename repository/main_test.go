@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Repository roots used for testing.
+var testRoots = []string{
+	"/iplant/home/shared/commons_repo/curated",
+	"/iplant/home/shared/commons_repo/curated_metadata/",
+}
+
+// newTestMatcher creates a matcher for testing, failing the test if the matcher can't be created.
+func newTestMatcher(t *testing.T, roots, include, exclude []string) *Matcher {
+	m, err := NewMatcher(exclude)
+	if err != nil {
+		t.Fatalf("unable to create the matcher: %s", err)
+	}
+	owner := &Owner{NodeID: "urn:node:test"}
+	for _, root := range roots {
+		if err := m.AddRoot(root, owner); err != nil {
+			t.Fatalf("unable to add a repository root: %s", err)
+		}
+	}
+	for _, spec := range include {
+		if err := m.AddInclude(spec, owner); err != nil {
+			t.Fatalf("unable to add an include rule: %s", err)
+		}
+	}
+	return m
+}
+
+// TestRoots verifies that paths beneath the repository roots match and that other paths don't.
+func TestRoots(t *testing.T) {
+	m := newTestMatcher(t, testRoots, nil, nil)
+
+	expected := map[string]bool{
+		"/iplant/home/shared/commons_repo/curated/foo.txt":          true,
+		"/iplant/home/shared/commons_repo/curated/a/b/c.txt":        true,
+		"/iplant/home/shared/commons_repo/curated_metadata/foo.xml": true,
+		"/iplant/home/shared/commons_repo/curated":                  false,
+		"/iplant/home/shared/commons_repo/curated_other/foo.txt":    false,
+		"/iplant/home/shared/foo.txt":                               false,
+		"relative/path":                                             false,
+	}
+	for path, want := range expected {
+		if got := m.Matches(path); got != want {
+			t.Errorf("Matches(%s): expected %t but got %t", path, want, got)
+		}
+	}
+}
+
+// TestExcludeRules verifies that exclude rules take precedence over include rules.
+func TestExcludeRules(t *testing.T) {
+	exclude := []string{
+		"*/.staging/*",
+		"glob:.*",
+		"prefix:/iplant/home/shared/commons_repo/curated/quarantine",
+	}
+	m := newTestMatcher(t, testRoots, nil, exclude)
+
+	expected := map[string]bool{
+		"/iplant/home/shared/commons_repo/curated/foo.txt":                  true,
+		"/iplant/home/shared/commons_repo/curated/a/.staging/foo.txt":       false,
+		"/iplant/home/shared/commons_repo/curated/a/.staging/b/foo.txt":     false,
+		"/iplant/home/shared/commons_repo/curated/.hidden":                  false,
+		"/iplant/home/shared/commons_repo/curated/a/.hidden/foo.txt":        false,
+		"/iplant/home/shared/commons_repo/curated/quarantine/foo.txt":       false,
+		"/iplant/home/shared/commons_repo/curated/quarantined/foo.txt":      true,
+		"/iplant/home/shared/commons_repo/curated/not.hidden/foo.txt":       true,
+		"/iplant/home/shared/commons_repo/curated_metadata/a/.staging/data": false,
+	}
+	for path, want := range expected {
+		if got := m.Matches(path); got != want {
+			t.Errorf("Matches(%s): expected %t but got %t", path, want, got)
+		}
+	}
+}
+
+// TestIncludeRules verifies that glob and regular expression include rules admit paths.
+func TestIncludeRules(t *testing.T) {
+	include := []string{
+		"glob:/iplant/home/shared/projects/*/published/**/*.csv",
+		`regex:^/iplant/home/shared/archive/[0-9]{4}/`,
+	}
+	m := newTestMatcher(t, nil, include, nil)
+
+	expected := map[string]bool{
+		"/iplant/home/shared/projects/p1/published/data.csv":      true,
+		"/iplant/home/shared/projects/p1/published/a/b/data.csv":  true,
+		"/iplant/home/shared/projects/p1/published/data.txt":      false,
+		"/iplant/home/shared/projects/p1/draft/data.csv":          false,
+		"/iplant/home/shared/projects/p1/p2/published/data.csv":   false,
+		"/iplant/home/shared/archive/2017/foo.txt":                true,
+		"/iplant/home/shared/archive/old/foo.txt":                 false,
+		"/iplant/home/shared/commons_repo/curated/data/stuff.csv": false,
+	}
+	for path, want := range expected {
+		if got := m.Matches(path); got != want {
+			t.Errorf("Matches(%s): expected %t but got %t", path, want, got)
+		}
+	}
+}
+
+// TestMostSpecificRule verifies that the most specific include rule is reported when several rules match.
+func TestMostSpecificRule(t *testing.T) {
+	roots := []string{"/iplant/home/shared", "/iplant/home/shared/commons_repo/curated"}
+	m := newTestMatcher(t, roots, nil, nil)
+
+	rule, ok := m.Match("/iplant/home/shared/commons_repo/curated/foo.txt")
+	if !ok {
+		t.Fatal("the path should have matched")
+	}
+	if rule.Pattern != "/iplant/home/shared/commons_repo/curated" {
+		t.Errorf("expected the most specific root but got %s", rule)
+	}
+}
+
+// TestOwners verifies that the owner of the matching root is reported.
+func TestOwners(t *testing.T) {
+	m := newTestMatcher(t, testRoots, nil, nil)
+	other := &Owner{NodeID: "urn:node:other", EventTypes: map[string]string{"read": "REPLICATE"}}
+	if err := m.AddRoot("/iplant/home/shared/other_repo", other); err != nil {
+		t.Fatalf("unable to add a repository root: %s", err)
+	}
+
+	rule, ok := m.Match("/iplant/home/shared/other_repo/foo.txt")
+	if !ok {
+		t.Fatal("the path should have matched")
+	}
+	if rule.Owner != other {
+		t.Errorf("expected the path to be owned by %s but got %s", other.NodeID, rule.Owner.NodeID)
+	}
+
+	rule, ok = m.Match("/iplant/home/shared/commons_repo/curated/foo.txt")
+	if !ok {
+		t.Fatal("the path should have matched")
+	}
+	if rule.Owner.NodeID != "urn:node:test" {
+		t.Errorf("expected the path to be owned by urn:node:test but got %s", rule.Owner.NodeID)
+	}
+}
+
+// TestInvalidRules verifies that invalid rules are rejected.
+func TestInvalidRules(t *testing.T) {
+	invalid := []string{"", "prefix:relative", "glob:/foo/[abc", "regex:(unclosed"}
+	for _, spec := range invalid {
+		if _, err := ParseRule(spec); err == nil {
+			t.Errorf("expected an error parsing rule `%s`", spec)
+		}
+	}
+}
+
+// BenchmarkLargeRuleSet measures matching performance with a large number of repository roots.
+func BenchmarkLargeRuleSet(b *testing.B) {
+	roots := make([]string, 10000)
+	for i := range roots {
+		roots[i] = fmt.Sprintf("/iplant/home/shared/collection%d/curated", i)
+	}
+	m, err := NewMatcher([]string{"*/.staging/*"})
+	if err != nil {
+		b.Fatalf("unable to create the matcher: %s", err)
+	}
+	for _, root := range roots {
+		if err := m.AddRoot(root, &Owner{NodeID: "urn:node:test"}); err != nil {
+			b.Fatalf("unable to add a repository root: %s", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Matches("/iplant/home/shared/collection9999/curated/a/b/c.txt")
+	}
+}
@@ -0,0 +1,65 @@
+package repository
+
+import "strings"
+
+// trie indexes rules by the path segments in their literal prefixes.
+type trie struct {
+	children map[string]*trie
+	rules    []*Rule
+}
+
+// newTrie creates an empty trie.
+func newTrie() *trie {
+	return &trie{children: make(map[string]*trie)}
+}
+
+// segments splits the directory portion of a literal prefix into path segments.
+func segments(prefix string) []string {
+	trimmed := strings.Trim(prefix, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// add adds a rule to the trie.
+func (t *trie) add(rule *Rule) {
+	node := t
+	for _, segment := range segments(rule.indexPrefix()) {
+		child, ok := node.children[segment]
+		if !ok {
+			child = newTrie()
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.rules = append(node.rules, rule)
+}
+
+// firstMatch returns the first rule stored in a node that matches a path, or nil if none of them match.
+func (t *trie) firstMatch(path string) *Rule {
+	for _, rule := range t.rules {
+		if rule.Matches(path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// match returns the most specific rule that matches a path, or nil if no rule matches. Rules stored deeper in the trie
+// are more specific. Rules stored at the same depth are preferred in the order in which they were added.
+func (t *trie) match(path string) *Rule {
+	best := t.firstMatch(path)
+
+	node := t
+	for _, segment := range segments(path) {
+		if node = node.children[segment]; node == nil {
+			break
+		}
+		if rule := node.firstMatch(path); rule != nil {
+			best = rule
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,155 @@
+// Package robots classifies events caused by automated harvesters, crawlers and other robots.
+//
+// Events are classified by the client's user agent using the COUNTER robots list, which contains regular expressions
+// that match the user agents of known robots. The list may be either the JSON file published by the COUNTER project,
+// which contains an array of objects with a pattern field, or a plain text file containing one pattern per line.
+// Blank lines and lines beginning with # are ignored in plain text files. Patterns are matched case-insensitively.
+// Events may also be classified by the client's IP address using a list of addresses and CIDR blocks.
+package robots
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Actions that can be taken for events caused by robots.
+const (
+	ActionFlag = "flag"
+	ActionDrop = "drop"
+)
+
+// Actions lists every supported action.
+var Actions = []string{ActionFlag, ActionDrop}
+
+// Options describes how robots are identified.
+type Options struct {
+	ListFile string
+	IPRanges []string
+}
+
+// Classifier determines whether or not events were caused by robots.
+type Classifier struct {
+	userAgents *regexp.Regexp
+	networks   []*net.IPNet
+}
+
+// parsePatterns extracts the patterns from the contents of a robots list file.
+func parsePatterns(contents []byte) ([]string, error) {
+	var patterns []string
+
+	// The COUNTER project publishes the list as an array of JSON objects.
+	if trimmed := bytes.TrimSpace(contents); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []struct {
+			Pattern string `json:"pattern"`
+		}
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Pattern != "" {
+				patterns = append(patterns, entry.Pattern)
+			}
+		}
+		return patterns, nil
+	}
+
+	// Otherwise, the file contains one pattern per line.
+	for _, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
+
+// LoadPatterns loads the user agent patterns from a robots list file.
+func LoadPatterns(path string) ([]string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := parsePatterns(contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the robots list %s: %s", path, err)
+	}
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern in the robots list %s: %s", path, err)
+		}
+	}
+	return patterns, nil
+}
+
+// ParseIPRange parses an IP address or CIDR block. A single address is treated as a block containing only that
+// address.
+func ParseIPRange(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		return network, err
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// NewClassifier creates a new Classifier. The patterns in the robots list are combined into a single regular
+// expression so that each user agent only has to be matched once.
+func NewClassifier(opts *Options) (*Classifier, error) {
+	c := &Classifier{}
+
+	if opts.ListFile != "" {
+		patterns, err := LoadPatterns(opts.ListFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(patterns) > 0 {
+			c.userAgents, err = regexp.Compile("(?i)(?:" + strings.Join(patterns, ")|(?:") + ")")
+			if err != nil {
+				return nil, fmt.Errorf("unable to compile the robots list %s: %s", opts.ListFile, err)
+			}
+		}
+	}
+
+	for _, s := range opts.IPRanges {
+		network, err := ParseIPRange(s)
+		if err != nil {
+			return nil, err
+		}
+		c.networks = append(c.networks, network)
+	}
+
+	return c, nil
+}
+
+// Robot returns true if an event from a client with a user agent and IP address was caused by a robot. Events
+// without a user agent or IP address are only classified using the information that they do contain.
+func (c *Classifier) Robot(userAgent, ipAddress string) bool {
+	if userAgent != "" && c.userAgents != nil && c.userAgents.MatchString(userAgent) {
+		return true
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(ipAddress)); ip != nil {
+		for _, network := range c.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,76 @@
+package robots
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// The contents of the robots lists used for testing.
+const (
+	testJSONList = `[
+  {"pattern": "bot", "last_changed": "2017-08-08"},
+  {"pattern": "^Apache-HttpClient", "last_changed": "2017-08-08"}
+]`
+
+	testTextList = `
+# Harvesters
+bot
+^Apache-HttpClient
+`
+)
+
+// writeList writes a robots list used for testing and returns its path.
+func writeList(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "robots")
+	if err != nil {
+		t.Fatalf("unable to create the robots list: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("unable to write the robots list: %s", err)
+	}
+	return f.Name()
+}
+
+// TestClassifier verifies that robots are identified by user agent and IP address using either list format.
+func TestClassifier(t *testing.T) {
+	for _, contents := range []string{testJSONList, testTextList} {
+		path := writeList(t, contents)
+		defer os.Remove(path)
+
+		c, err := NewClassifier(&Options{ListFile: path, IPRanges: []string{"192.0.2.0/24", "2001:db8::1"}})
+		if err != nil {
+			t.Fatalf("unable to create the classifier: %s", err)
+		}
+
+		expected := []struct {
+			userAgent string
+			ipAddress string
+			robot     bool
+		}{
+			{"Mozilla/5.0 (compatible; Googlebot/2.1)", "", true},
+			{"Apache-HttpClient/4.5.2", "198.51.100.1", true},
+			{"Mozilla/5.0 (X11; Linux x86_64) Firefox/60.0", "198.51.100.1", false},
+			{"Mozilla/5.0 (X11; Linux x86_64) Firefox/60.0", "192.0.2.15", true},
+			{"", "2001:db8::1", true},
+			{"", "2001:db8::2", false},
+			{"", "", false},
+		}
+		for _, tc := range expected {
+			if robot := c.Robot(tc.userAgent, tc.ipAddress); robot != tc.robot {
+				t.Errorf("Robot(%q, %q): expected %t but got %t", tc.userAgent, tc.ipAddress, tc.robot, robot)
+			}
+		}
+	}
+}
+
+// TestInvalidPattern verifies that a robots list containing an invalid pattern is rejected.
+func TestInvalidPattern(t *testing.T) {
+	path := writeList(t, "bot\n(unclosed\n")
+	defer os.Remove(path)
+
+	if _, err := NewClassifier(&Options{ListFile: path}); err == nil {
+		t.Error("an error was expected but none was returned")
+	}
+}
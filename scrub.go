@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/cyverse-de/dataone-indexer/logger"
+)
+
+// scrubIdentities periodically removes user identifiers from events that are older than the retention period. The
+// events themselves are kept so that aggregate counts continue to work. The bodies of archived messages are removed
+// as well if the archive is enabled, because they contain the same identifiers.
+func (svc *DataoneIndexer) scrubIdentities(retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := time.Now().Add(-retention)
+		count, err := database.ScrubIdentities(context.Background(), svc.db, cutoff)
+		if err != nil {
+			logger.Log.Errorf("unable to scrub user identifiers: %s", err)
+		} else if count > 0 {
+			logger.Log.Infof("removed user identifiers from %d event(s) logged before %s", count, cutoff)
+		}
+
+		svc.scrubArchive(cutoff)
+		<-ticker.C
+	}
+}
+
+// scrubArchive removes the bodies and user identifiers from archived messages received before a cutoff time. Nothing
+// is done if the archive is disabled.
+func (svc *DataoneIndexer) scrubArchive(cutoff time.Time) {
+	if svc.archive == nil {
+		return
+	}
+
+	count, err := svc.archive.ScrubIdentities(context.Background(), cutoff)
+	if err != nil {
+		logger.Log.Errorf("unable to scrub user identifiers from the message archive: %s", err)
+	} else if count > 0 {
+		logger.Log.Infof("removed user identifiers from %d archived message(s) received before %s", count, cutoff)
+	}
+}
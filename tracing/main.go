@@ -0,0 +1,184 @@
+// Package tracing configures OpenTelemetry distributed tracing for the DataONE indexer.
+//
+// Trace context is propagated using the W3C Trace Context traceparent header, which the DE data service includes in
+// the headers of the AMQP messages that it publishes. Completed spans are exported by the OpenTelemetry SDK, either to
+// standard output or to an OpenTelemetry collector using OTLP over HTTP.
+package tracing
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// scopeName is the instrumentation scope name reported to OpenTelemetry collectors.
+const scopeName = "github.com/cyverse-de/dataone-indexer"
+
+// shutdownTimeout is the maximum amount of time to wait for queued spans to be exported during shutdown.
+const shutdownTimeout = 10 * time.Second
+
+// propagator extracts trace context from message headers.
+var propagator = propagation.TraceContext{}
+
+// Provider creates the tracers used by the indexer and exports the spans that they produce. A Provider without an
+// SDK tracer provider discards all spans.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// newResource describes the service that produces spans.
+func newResource(serviceName string) *resource.Resource {
+	return resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+}
+
+// NewNoopProvider creates a new Provider that discards all spans.
+func NewNoopProvider() *Provider {
+	return &Provider{}
+}
+
+// NewWriterProvider creates a new Provider that writes each completed span to a writer such as standard output as
+// soon as it's finished.
+func NewWriterProvider(serviceName string, w io.Writer) (*Provider, error) {
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(newResource(serviceName)),
+		sdktrace.WithSyncer(exporter),
+	)
+	return &Provider{tp: tp}, nil
+}
+
+// NewOTLPProvider creates a new Provider that sends batches of completed spans to an OpenTelemetry collector using
+// OTLP over HTTP. The endpoint is the base URL of the collector, for example http://otel-collector:4318.
+func NewOTLPProvider(serviceName, endpoint string, batchSize int, flushInterval time.Duration) (*Provider, error) {
+	exporter, err := otlptracehttp.New(
+		context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(newResource(serviceName)),
+		sdktrace.WithBatcher(
+			exporter,
+			sdktrace.WithMaxExportBatchSize(batchSize),
+			sdktrace.WithMaxQueueSize(8*batchSize),
+			sdktrace.WithBatchTimeout(flushInterval),
+		),
+	)
+	return &Provider{tp: tp}, nil
+}
+
+// tracerProvider returns the OpenTelemetry tracer provider used by a Provider.
+func (p *Provider) tracerProvider() trace.TracerProvider {
+	if p.tp == nil {
+		return noop.NewTracerProvider()
+	}
+	return p.tp
+}
+
+// Shutdown exports any queued spans and stops the provider. Spans that are finished after the provider is shut down
+// are discarded, so it's safe to call Shutdown while other goroutines are still processing messages. Calling
+// Shutdown more than once has no effect.
+func (p *Provider) Shutdown() error {
+	if p.tp == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return p.tp.Shutdown(ctx)
+}
+
+// The provider used by the package level functions.
+var (
+	globalMu       sync.RWMutex
+	globalProvider = NewNoopProvider()
+)
+
+// SetProvider sets the provider used by the package level functions. It also registers the provider and the W3C
+// Trace Context propagator with OpenTelemetry so that any instrumented libraries use them as well.
+func SetProvider(p *Provider) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalProvider = p
+	otel.SetTracerProvider(p.tracerProvider())
+	otel.SetTextMapPropagator(propagator)
+}
+
+// GetProvider returns the provider used by the package level functions.
+func GetProvider() *Provider {
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return globalProvider
+}
+
+// Tracer returns the tracer used to create spans.
+func Tracer() trace.Tracer {
+	return GetProvider().tracerProvider().Tracer(scopeName)
+}
+
+// Start creates a new internal span as a child of the span in the given context.
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name)
+}
+
+// SetError records an error on a span and marks the span as failed if the error is not nil.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// headerCarrier adapts the headers of an AMQP message for use with OpenTelemetry propagators. Header values may be
+// strings or byte slices.
+type headerCarrier map[string]interface{}
+
+// Get returns the value of a header, or an empty string if the header isn't present or isn't a string.
+func (c headerCarrier) Get(key string) string {
+	switch v := c[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+// Set sets the value of a header.
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys returns the names of the headers.
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ExtractFromHeaders returns a context containing the remote span context found in a set of message headers. The
+// original context is returned if the headers don't contain a valid traceparent header.
+func ExtractFromHeaders(ctx context.Context, headers map[string]interface{}) context.Context {
+	return propagator.Extract(ctx, headerCarrier(headers))
+}
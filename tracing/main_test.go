@@ -0,0 +1,179 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The traceparent used for testing, taken from the W3C Trace Context specification.
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordingExporter records exported spans in memory. Unlike the in-memory exporter that it wraps, it keeps the
+// recorded spans when it's shut down.
+type recordingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+// Shutdown does nothing.
+func (e recordingExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// newRecordingProvider returns a provider that records finished spans in memory, along with the exporter. Spans are
+// batched if batch is true.
+func newRecordingProvider(batch bool) (*Provider, recordingExporter) {
+	exporter := recordingExporter{tracetest.NewInMemoryExporter()}
+	option := sdktrace.WithSyncer(exporter)
+	if batch {
+		option = sdktrace.WithBatcher(exporter)
+	}
+	return &Provider{tp: sdktrace.NewTracerProvider(option)}, exporter
+}
+
+// TestExtractFromHeaders verifies that the remote span context can be extracted from a traceparent header.
+func TestExtractFromHeaders(t *testing.T) {
+	for _, value := range []interface{}{testTraceparent, []byte(testTraceparent)} {
+		ctx := ExtractFromHeaders(context.Background(), map[string]interface{}{"traceparent": value})
+		sc := trace.SpanContextFromContext(ctx)
+		if !sc.IsValid() || !sc.IsRemote() {
+			t.Fatalf("no remote span context was extracted from %T header", value)
+		}
+		if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("unexpected trace ID: %s", sc.TraceID())
+		}
+		if sc.SpanID().String() != "00f067aa0ba902b7" {
+			t.Errorf("unexpected span ID: %s", sc.SpanID())
+		}
+		if !sc.IsSampled() {
+			t.Error("the sampled flag was not extracted")
+		}
+	}
+}
+
+// TestInvalidTraceparent verifies that invalid traceparent headers are ignored.
+func TestInvalidTraceparent(t *testing.T) {
+	invalid := []interface{}{
+		"",
+		42,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, value := range invalid {
+		ctx := ExtractFromHeaders(context.Background(), map[string]interface{}{"traceparent": value})
+		if trace.SpanContextFromContext(ctx).IsValid() {
+			t.Errorf("a span context was extracted from `%v`", value)
+		}
+	}
+}
+
+// TestSpanParenting verifies that spans continue the trace found in message headers.
+func TestSpanParenting(t *testing.T) {
+	p, exporter := newRecordingProvider(false)
+	SetProvider(p)
+	defer SetProvider(NewNoopProvider())
+
+	headers := map[string]interface{}{"traceparent": []byte(testTraceparent)}
+	ctx := ExtractFromHeaders(context.Background(), headers)
+	ctx, parent := Tracer().Start(ctx, "parent", trace.WithSpanKind(trace.SpanKindConsumer))
+	_, child := Start(ctx, "child")
+	child.End()
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 exported spans but got %d", len(spans))
+	}
+	childStub, parentStub := spans[0], spans[1]
+	if parentStub.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("the parent span did not continue the remote trace")
+	}
+	if parentStub.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("the parent span is not a child of the remote span")
+	}
+	if parentStub.SpanKind != trace.SpanKindConsumer {
+		t.Errorf("unexpected span kind: %s", parentStub.SpanKind)
+	}
+	if childStub.Parent.SpanID() != parentStub.SpanContext.SpanID() {
+		t.Errorf("the child span is not a child of the parent span")
+	}
+}
+
+// TestNewTrace verifies that a new trace is started when there's no trace context.
+func TestNewTrace(t *testing.T) {
+	p, exporter := newRecordingProvider(false)
+	SetProvider(p)
+	defer SetProvider(NewNoopProvider())
+
+	_, span := Start(context.Background(), "root")
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || !spans[0].SpanContext.IsValid() {
+		t.Fatal("a new span should have a valid span context")
+	}
+	if spans[0].Parent.IsValid() {
+		t.Error("a root span should not have a parent")
+	}
+}
+
+// TestWriterProvider verifies that finished spans are written to the writer.
+func TestWriterProvider(t *testing.T) {
+	var buf bytes.Buffer
+	p, err := NewWriterProvider("test-service", &buf)
+	if err != nil {
+		t.Fatalf("unable to create the provider: %s", err)
+	}
+	SetProvider(p)
+	defer SetProvider(NewNoopProvider())
+
+	_, span := Start(context.Background(), "operation")
+	span.End()
+
+	var exported struct {
+		Name        string
+		SpanContext struct {
+			SpanID string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &exported); err != nil {
+		t.Fatalf("unable to decode exported span: %s", err)
+	}
+	if exported.Name != "operation" || exported.SpanContext.SpanID != span.SpanContext().SpanID().String() {
+		t.Errorf("unexpected exported span: %s", buf.String())
+	}
+}
+
+// TestShutdown verifies that queued spans are exported during shutdown and that spans finished after shutdown are
+// discarded.
+func TestShutdown(t *testing.T) {
+	p, exporter := newRecordingProvider(true)
+	SetProvider(p)
+	defer SetProvider(NewNoopProvider())
+
+	_, before := Start(context.Background(), "before")
+	_, after := Start(context.Background(), "after")
+	before.End()
+
+	if err := p.Shutdown(); err != nil {
+		t.Fatalf("unable to shut down the provider: %s", err)
+	}
+	after.End()
+	if err := p.Shutdown(); err != nil {
+		t.Errorf("shutting down the provider a second time failed: %s", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "before" {
+		t.Errorf("unexpected exported spans: %v", spans)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/cyverse-de/dataone-indexer/database"
+	"github.com/spf13/viper"
+)
+
+// verifyLog walks the hash chain over the events recorded for each member node, or for a single node if one is
+// specified, and reports the first break in each chain along with the number of events deleted by user erasures. Gaps
+// left by erasures don't break the chain. The command exits with a non-zero status if any chain is broken.
+func verifyLog(cfg *viper.Viper, nodeID string) {
+	db, err := getDbConnection(cfg.GetString("db.uri"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to establish the database connection: %s\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	nodeIDs := []string{nodeID}
+	if nodeID == "" {
+		if nodeIDs, err = database.ChainNodes(ctx, db); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to list the member nodes: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	broken := false
+	for _, nodeID := range nodeIDs {
+		report, err := database.VerifyChain(ctx, db, nodeID)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s: unable to verify the event log: %s\n", nodeID, err)
+			os.Exit(1)
+		case report.Break != nil:
+			fmt.Printf("%s: chain broken after %d verified event(s): %s\n", nodeID, report.Verified, report.Break)
+			broken = true
+		default:
+			fmt.Printf("%s: %d event(s) verified\n", nodeID, report.Verified)
+		}
+		if report.Erased > 0 {
+			fmt.Printf("%s: %d event(s) deleted by user erasures\n", nodeID, report.Erased)
+		}
+	}
+
+	if broken {
+		os.Exit(1)
+	}
+}